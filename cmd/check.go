@@ -0,0 +1,92 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/konflux-workspaces/rbac-migration/pkg/migration"
+	"github.com/spf13/cobra"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+var checkOpts = &migration.MigrateOptions{}
+
+// checkCmd represents the check command
+var checkCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Check sub-command",
+	Long: `Check subcommand validating connectivity to k8s and to the LDAP server or --identity-url
+	before migrating, without reading or changing any RoleBinding. Exits non-zero if any check fails`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//Failed checks are not usage errors
+		cmd.SilenceUsage = true
+		opts := checkOpts
+		failed := 0
+
+		report := func(name string, err error) {
+			if err != nil {
+				logError(fmt.Sprintf("[FAIL] %s: %v", name, err), "check", name, "error", err)
+				failed++
+				return
+			}
+			logInfo(fmt.Sprintf("[PASS] %s", name), "check", name)
+		}
+
+		//The same config as migrate, so --as and --proxy-url are checked too
+		config, err := opts.RESTConfig()
+		report("Load kubeconfig", err)
+
+		if err == nil {
+			dynclient, err := dynamic.NewForConfig(config)
+			for _, namespace := range opts.UserAccountNamespaces {
+				listErr := err
+				if listErr == nil {
+					_, listErr = dynclient.Resource(migration.UserAccountGVR).Namespace(namespace).List(cmd.Context(), metav1.ListOptions{Limit: 1})
+				}
+				report(fmt.Sprintf("List UserAccounts in %s namespace", namespace), listErr)
+			}
+
+			clientset, err := kubernetes.NewForConfig(config)
+			if err == nil {
+				_, err = clientset.CoreV1().Namespaces().List(cmd.Context(), metav1.ListOptions{Limit: 1})
+			}
+			report("List Namespaces", err)
+		}
+
+		//Only the directory migrate resolves the emails with is checked
+		switch {
+		case opts.Target == "email":
+			logInfo("[SKIP] Directory: the 'email' target resolves no user", "check", "Directory")
+		case opts.Identity.URL != "":
+			report("Query identity endpoint", migration.CheckIdentity(&opts.Identity, cmd.Context()))
+		default:
+			conn, err := migration.DialLDAP(&opts.LDAP)
+			report("Connect to LDAP server", err)
+			if err == nil {
+				conn.Close()
+			}
+		}
+
+		if failed > 0 {
+			return &exitError{code: exitFailed, err: fmt.Errorf("%d checks failed", failed)}
+		}
+
+		logInfo("All checks passed")
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	addKubeFlags(checkCmd.Flags(), checkOpts)
+	addLDAPFlags(checkCmd.Flags(), &checkOpts.LDAP)
+	addIdentityFlags(checkCmd.Flags(), &checkOpts.Identity)
+	checkCmd.Flags().StringVarP(&checkOpts.Target, "target", "t", migration.DefaultMigrateOptions().Target, "Target identity attribute of the migration, 'email' needs no directory to check")
+	checkCmd.Flags().StringSliceVar(&checkOpts.UserAccountNamespaces, "useraccount-namespace", migration.DefaultMigrateOptions().UserAccountNamespaces, "Namespaces where the toolchain UserAccounts are listed from, repeat or comma separate it for several member namespaces")
+}
@@ -0,0 +1,100 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/konflux-workspaces/rbac-migration/pkg/migration"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+// configCmd represents the config command
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Config sub-command",
+	Long: `Config subcommand printing the effective migrate configuration after merging
+	flags, env and config file, without performing any action. It accepts the same flags as migrate`,
+	Run: func(cmd *cobra.Command, args []string) {
+		opts := migrateOpts
+
+		context, server := "<unknown>", "<unknown>"
+		kubeconfig := opts.Kubeconfig
+		if kubeconfig == "" {
+			kubeconfig = strings.Join(clientcmd.NewDefaultClientConfigLoadingRules().GetLoadingPrecedence(), string(filepath.ListSeparator))
+		}
+		if rawConfig, err := migration.KubeClientConfig(opts.Kubeconfig).RawConfig(); err != nil {
+			logWarn(fmt.Sprintf("Failed to load kubeconfig %s: %v", kubeconfig, err), "error", err)
+		} else {
+			context = rawConfig.CurrentContext
+			if kubeContext, ok := rawConfig.Contexts[context]; ok {
+				if cluster, ok := rawConfig.Clusters[kubeContext.Cluster]; ok {
+					server = cluster.Server
+				}
+			}
+		}
+
+		fmt.Fprintf(statusOut, "Kubeconfig:  %s\n", kubeconfig)
+		fmt.Fprintf(statusOut, "Context:     %s\n", context)
+		fmt.Fprintf(statusOut, "Cluster:     %s\n", server)
+		fmt.Fprintf(statusOut, "LDAP host:   %s\n", opts.LDAP.Host)
+		if cfgFile != "" {
+			fmt.Fprintf(statusOut, "Config file: %s\n", cfgFile)
+		}
+		fmt.Fprintf(statusOut, "Flags:\n")
+
+		cmd.Flags().VisitAll(func(f *pflag.Flag) {
+			if f.Name == "help" {
+				return
+			}
+			value := f.Value.String()
+			if secretFlags[f.Name] && value != "" {
+				value = "<redacted>"
+			} else if urlFlags[f.Name] && value != "" {
+				value = redactURL(value)
+			}
+			fmt.Fprintf(statusOut, "  %s: %s\n", f.Name, value)
+		})
+	},
+}
+
+// secretFlags are the flags whose value config never prints
+var secretFlags = map[string]bool{
+	"identity-token":     true,
+	"ldap-bind-password": true,
+}
+
+// urlFlags are the flags whose URL may carry credentials, in its userinfo or a pre-signed query
+var urlFlags = map[string]bool{
+	"proxy-url":          true,
+	"identity-proxy-url": true,
+	"ldap-proxy-url":     true,
+	"output-url":         true,
+}
+
+// redactURL returns value with its userinfo and query redacted, or redacted whole when it does not parse
+func redactURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "<redacted>"
+	}
+	if u.User != nil {
+		u.User = url.User("redacted")
+	}
+	if u.RawQuery != "" {
+		u.RawQuery = "redacted"
+	}
+	return u.String()
+}
+
+func init() {
+	rootCmd.AddCommand(configCmd)
+	// migrate flags are shared with config in migrate.go init, once they are defined
+}
@@ -0,0 +1,34 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package cmd
+
+import "fmt"
+
+// Exit codes of wscli, documented in the README
+const (
+	// exitFailed is returned for errors, invalid options and interrupted runs
+	exitFailed = 1
+	// exitIncomplete is returned when the migration completed with unresolved accounts or orphan Tenant Namespaces
+	exitIncomplete = 2
+	// exitApplyFailed is returned when --keep-going applied the migrated RoleBindings with failures
+	exitApplyFailed = 3
+)
+
+// exitError ends a command with a specific exit code, err is printed when set
+type exitError struct {
+	code int
+	err  error
+}
+
+func (e *exitError) Error() string {
+	if e.err == nil {
+		return fmt.Sprintf("exit code %d", e.code)
+	}
+	return e.err.Error()
+}
+
+func (e *exitError) Unwrap() error {
+	return e.err
+}
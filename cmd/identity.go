@@ -0,0 +1,18 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package cmd
+
+import (
+	"github.com/konflux-workspaces/rbac-migration/pkg/migration"
+	"github.com/spf13/pflag"
+)
+
+// addIdentityFlags binds the HTTP identity endpoint flags to o
+func addIdentityFlags(flags *pflag.FlagSet, o *migration.IdentityOptions) {
+	flags.StringVar(&o.URL, "identity-url", "", "Resolve emails with a GET to this OIDC userinfo or SCIM URL instead of LDAP, the email replaces {email} or is sent as the email query parameter")
+	flags.StringVar(&o.Token, "identity-token", "", "Bearer token of --identity-url, better set through WSCLI_IDENTITY_TOKEN")
+	flags.StringVar(&o.ProxyURL, "identity-proxy-url", "", "http:// or https:// proxy of the --identity-url requests, overriding HTTPS_PROXY and NO_PROXY")
+	flags.StringVar(&o.IDField, "id-json-field", migration.DefaultIDJSONField, "Dotted path of the user name in the --identity-url JSON response, e.g. Resources.0.userName")
+}
@@ -0,0 +1,21 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package cmd
+
+import (
+	"github.com/konflux-workspaces/rbac-migration/pkg/migration"
+	"github.com/spf13/pflag"
+)
+
+// kubeconfigUsage describes the --kubeconfig flag, which falls back to the kubectl loading rules when unset
+const kubeconfigUsage = "Path to the kubeconfig file, overriding the files listed in $KUBECONFIG and ~/.kube/config"
+
+// addKubeFlags binds the k8s connection flags to o
+func addKubeFlags(flags *pflag.FlagSet, o *migration.MigrateOptions) {
+	flags.StringVar(&o.Kubeconfig, "kubeconfig", "", kubeconfigUsage)
+	flags.StringVar(&o.As, "as", "", "Username to impersonate for the k8s operations, like kubectl --as")
+	flags.StringArrayVar(&o.AsGroups, "as-group", nil, "Group to impersonate for the k8s operations, can be repeated, requires --as")
+	flags.StringVar(&o.ProxyURL, "proxy-url", "", "http:// or https:// proxy of the k8s API server connections, overriding HTTPS_PROXY, NO_PROXY and the kubeconfig proxy-url")
+}
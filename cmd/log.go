@@ -0,0 +1,133 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+)
+
+// statusOut is where informational text output goes, stderr when the YAML is written to stdout
+var statusOut io.Writer = os.Stdout
+
+// jsonLogger emits JSON lines to stderr when --log-format json is selected, nil in text mode
+var jsonLogger *slog.Logger
+
+var logFormat string
+
+// quiet suppresses the informational and warning output, errors and the JSON summary are still emitted
+var quiet bool
+
+// statusLevel returns the lowest level of the status messages printed, error when --quiet is set
+// and debug when verbose is
+func statusLevel(verbose bool) slog.Level {
+	if quiet {
+		return slog.LevelError
+	}
+	if verbose {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
+
+// setupLogging selects the text or json output for all status and error messages
+func setupLogging(format string) error {
+	switch format {
+	case "text":
+		jsonLogger = nil
+	case "json":
+		jsonLogger = slog.New(slog.NewJSONHandler(os.Stderr, nil))
+	default:
+		return fmt.Errorf("invalid --log-format %q, select between 'text' and 'json'", format)
+	}
+
+	return nil
+}
+
+// logInfo prints an informational message, attrs are slog key/value pairs only emitted in json mode
+func logInfo(msg string, attrs ...any) {
+	if quiet {
+		return
+	}
+
+	if jsonLogger != nil {
+		jsonLogger.Info(msg, attrs...)
+		return
+	}
+
+	fmt.Fprintln(statusOut, msg)
+}
+
+// logWarn prints a warning message, attrs are slog key/value pairs only emitted in json mode
+func logWarn(msg string, attrs ...any) {
+	if quiet {
+		return
+	}
+
+	if jsonLogger != nil {
+		jsonLogger.Warn(msg, attrs...)
+		return
+	}
+
+	fmt.Fprintln(statusOut, msg)
+}
+
+// logError prints an error message to stderr, attrs are slog key/value pairs only emitted in json mode
+func logError(msg string, attrs ...any) {
+	if jsonLogger != nil {
+		jsonLogger.Error(msg, attrs...)
+		return
+	}
+
+	log.Print(msg)
+}
+
+// logFatal prints an error message to stderr and exits non-zero
+func logFatal(msg string, attrs ...any) {
+	logError(msg, attrs...)
+	os.Exit(1)
+}
+
+// newLogger returns the logger handed to the migration library, writing through the selected output.
+// Debug records are only emitted when verbose is set
+func newLogger(verbose bool) *slog.Logger {
+	if jsonLogger != nil {
+		return slog.New(slog.NewJSONHandler(os.Stderr, &slog.HandlerOptions{Level: statusLevel(verbose)}))
+	}
+
+	return slog.New(statusHandler{level: statusLevel(verbose)})
+}
+
+// statusHandler is the slog.Handler of the text output, printing the bare message of debug, info and
+// warning records to statusOut and of error records to stderr as logError does
+type statusHandler struct {
+	level slog.Level
+}
+
+func (h statusHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (statusHandler) Handle(_ context.Context, r slog.Record) error {
+	if r.Level >= slog.LevelError {
+		log.Print(r.Message)
+		return nil
+	}
+
+	_, err := fmt.Fprintln(statusOut, r.Message)
+	return err
+}
+
+func (h statusHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h
+}
+
+func (h statusHandler) WithGroup(name string) slog.Handler {
+	return h
+}
@@ -5,44 +5,28 @@ Copyright © 2025 Red Hat, Inc.
 package cmd
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
-	"path/filepath"
-	"regexp"
 	"strings"
-	"sync"
+	"time"
 
-	ldap "github.com/go-ldap/ldap/v3"
+	"github.com/konflux-workspaces/rbac-migration/pkg/migration"
 	"github.com/spf13/cobra"
-	rbacv1 "k8s.io/api/rbac/v1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/apimachinery/pkg/runtime/schema"
-	"k8s.io/apimachinery/pkg/runtime/serializer/json"
-	"k8s.io/client-go/dynamic"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 )
 
-// LDAPClient Structure for holding singleton LDAP connection
-type LDAPClient struct {
-	conn *ldap.Conn
-}
+var migrateOpts = &migration.MigrateOptions{}
 
-// Transform is a Functor Type
-type Transform func(string) string
+// showProgress enables the progress counters on stderr
+var showProgress bool
 
-var target string
-var kubeconfig string
-var outputFile string
+// timeout bounds the whole migrate run, 0 for no bound
+var timeout time.Duration
 
-var (
-	instance *LDAPClient
-	once     sync.Once
-)
+// assumeYes confirms --prune without prompting
+var assumeYes bool
 
 // migrateCmd represents the migrate command
 var migrateCmd = &cobra.Command{
@@ -50,357 +34,194 @@ var migrateCmd = &cobra.Command{
 	Short: "Migrate sub-command",
 	Long: `Migrate subcommand making calls to k8s to migrate tenanat RoleBndings
 	from KubeSaw accounts to sso users`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
+		//Flag errors still print the usage, not the errors of the run
+		cmd.SilenceUsage = true
 
-		//Load KubeConfig
-		config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-		if err != nil {
-			log.Fatalf("Failed to load kubeconfig: %v", err)
-		}
+		opts := *migrateOpts
 
-		//Init dynamic client
-		dynclient, err := dynamic.NewForConfig(config)
-		if err != nil {
-			log.Fatalf("Failed to create k8s client: %v", err)
+		//Keeping stdout for the YAML stream or the effective bindings only
+		if opts.OutputFile == "-" || opts.EffectiveBindingsOnly {
+			statusOut = os.Stderr
 		}
 
-		//Get User Accounts
-		userAcctGVR := schema.GroupVersionResource{
-			Group:    "toolchain.dev.openshift.com",
-			Version:  "v1alpha1",
-			Resource: "useraccounts",
+		if !cmd.Flags().Changed("progress") {
+			showProgress = isStderrTerminal()
 		}
-
-		userAccounts, err := dynclient.Resource(userAcctGVR).Namespace("toolchain-member-operator").List(cmd.Context(), metav1.ListOptions{})
-		if err != nil {
-			log.Fatalf("Failed to list user accounts: %v", err)
+		if showProgress && !quiet {
+			opts.Progress = reportProgress
 		}
 
-		fmt.Printf("Found %d user accounts in toolchain-member-operator namespace:\n", len(userAccounts.Items))
-
-		var idMap map[string]string
-		switch target {
-		case "email":
-			fmt.Println("migrate called for email")
-			idMap = buildIDMap(userAccounts, cleanEmail)
-		case "user":
-			lc := getLDAPClient()
-			fmt.Println("migrate called for user name")
-			idMap = buildIDMap(userAccounts, getUser)
-			lc.conn.Close()
-		default:
-			fmt.Println("Please select the target identity attribute by passing -t Flag")
-			cmd.Help()
-			return
+		//Explicit UserAccount namespace wins over --all-namespaces
+		if cmd.Flags().Changed("useraccount-namespace") {
+			opts.AllNamespaces = false
 		}
 
-		migrate(idMap, cmd.Context())
-	},
-}
-
-func getLDAPClient() *LDAPClient {
-	once.Do(func() {
-		ldapServer := "ldap.corp.redhat.com"
-		ldapPort := 389
-		ldapHost := fmt.Sprintf("%s:%d", ldapServer, ldapPort)
-		conn, err := ldap.Dial("tcp", ldapHost)
-
-		if err != nil {
-			log.Fatalf("Failed to connect to LDAP server: %v", err)
-		}
-
-		instance = &LDAPClient{conn: conn}
-	})
-	return instance
-}
-
-func cleanEmail(email string) string {
-	re := regexp.MustCompile(`\+[^@]+@`)
-
-	cEmail := re.ReplaceAllString(email, "@")
-
-	return cEmail
-}
-
-func searchLDAP(email string, emailField string) string {
-	lc := getLDAPClient()
-
-	searchBase := "ou=users,dc=redhat,dc=com"
-	searchFilter := fmt.Sprintf("(%s=%s)", emailField, email)
-
-	searchRequest := ldap.NewSearchRequest(
-		searchBase,
-		ldap.ScopeWholeSubtree,
-		ldap.NeverDerefAliases,
-		0, 0, false,
-		searchFilter,
-		[]string{"uid"},
-		nil,
-	)
-
-	sr, err := lc.conn.Search(searchRequest)
-
-	if err != nil {
-		log.Fatalf("Error found searching for email %s: %v\n", email, err)
-	}
-
-	if len(sr.Entries) == 0 {
-		
-		return ""
-	}
-
-	return sr.Entries[0].GetAttributeValue("uid")
-}
-
-func getUser(email string) string {
-	cEmail := cleanEmail(email)
-
-	// searching by mail
-	userName := searchLDAP(cEmail, "mail")
+		opts.Logger = newLogger(opts.Verbose)
+		opts.ConfirmPrune = confirmPrune
 
-	if userName == "" {
-		// trying search by alias
-		userName = searchLDAP(cEmail, "rhatPreferredAlias")
-
-		if userName == "" {
-			fmt.Printf("No user found for email %s\n", cEmail)
+		ctx := cmd.Context()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeoutCause(ctx, timeout, fmt.Errorf("--timeout %s exceeded", timeout))
+			defer cancel()
 		}
-	}
-
-	return userName
-	
-}
 
-func buildIDMap(userAccounts *unstructured.UnstructuredList, transform Transform) map[string]string {
-	idMap := make(map[string]string)
-	for _, account := range userAccounts.Items {
-		name := account.GetName()
-		spec, ok := account.Object["spec"].(map[string]interface{})
-		if !ok {
-			fmt.Printf("UserAccount %s: spec not found")
-			continue
+		result, err := migration.Run(ctx, opts)
+		if errors.Is(err, migration.ErrInvalidOptions) {
+			logError(err.Error(), "error", err)
+			cmd.Help()
+			return &exitError{code: exitFailed}
 		}
-
-		claims, ok := spec["propagatedClaims"].(map[string]interface{})
-		if !ok {
-			fmt.Printf("UserAccount %s: claims not found")
-			continue
+		if errors.Is(err, migration.ErrApplyFailed) && ctx.Err() == nil {
+			printNamespaceSummaries(result.Namespaces)
+			printSummary(result.Stats)
+			return &exitError{code: exitApplyFailed, err: fmt.Errorf("Migration failed: %w", err)}
 		}
-
-		email, ok := claims["email"].(string)
-		if !ok {
-			fmt.Printf("UserAccount %s: email not found")
-			continue
+		if err != nil {
+			//Reporting the progress made before the run was cancelled
+			if ctx.Err() != nil {
+				printSummary(result.Stats)
+			}
+			return fmt.Errorf("Migration failed: %w", err)
 		}
 
-		id := transform(email)
-
-		if id != "" { //no need to map if empty since id was not found
-			idMap[name] = id
+		printDiffs(result.Diffs)
+		printNamespaceSummaries(result.Namespaces)
+		printSummary(result.Stats)
+		if opts.EffectiveBindingsOnly {
+			printEffectiveBindings(result.RoleBindings)
 		}
 
-	}
-
-	return idMap
-}
-
-func getTenantNamespaces(clientset *kubernetes.Clientset, ctx context.Context) []string {
-	//Get Namespaces
-	labelSelector := "toolchain.dev.openshift.com/type=tenant"
-
-	ns, err := clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
-	if err != nil {
-		log.Fatalf("Failed to list namespace: %v", err)
-	}
-
-	nsNum := len(ns.Items)
-
-	namespaces := make([]string, 0, nsNum)
-
-	for _, namespace := range ns.Items {
-		nsName := namespace.Name
-		namespaces = append(namespaces, nsName)
-	}
-
-	return namespaces
-}
-
-func getTenantRoleBindings(clientset *kubernetes.Clientset, ctx context.Context) []rbacv1.RoleBinding {
-	//Get RoleBindings
-	labelSelector := "toolchain.dev.openshift.com/provider=codeready-toolchain"
-
-	fmt.Printf("Gathering information for Tenant Namespaces\n")
-
-	rbs, err := clientset.RbacV1().RoleBindings("").List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
-	if err != nil {
-		log.Fatalf("Failed to list Tenant RoleBindings: %v", err)
-	}
-
-	rbList := make([]rbacv1.RoleBinding, 0, len(rbs.Items))
-
-	for _, rb := range rbs.Items {
-		rbName := rb.Name
-		if rbName == "appstudio-pipelines-runner-rolebinding" {
-			continue
+		if len(result.Unresolved) > 0 || len(result.OrphanNamespaces) > 0 {
+			logWarn(fmt.Sprintf("Migration incomplete: %d unresolved accounts, %d orphan Tenant Namespaces", len(result.Unresolved), len(result.OrphanNamespaces)),
+				"unresolved", len(result.Unresolved), "orphan_namespaces", len(result.OrphanNamespaces))
+			return &exitError{code: exitIncomplete}
 		}
-		rbList = append(rbList, rb)
-	}
 
-	return rbList
+		return nil
+	},
 }
 
-func mutateTenantRoleBindings(idMap map[string]string, rbList []rbacv1.RoleBinding) []rbacv1.RoleBinding {
-	mrbList := make([]rbacv1.RoleBinding, 0, len(rbList))
-	processedNamespaces := make(map[string]int)
-
-	for _, rb := range rbList {
-		namespace := rb.Namespace
-		_, exists := processedNamespaces[namespace]
-		if !exists {
-			processedNamespaces[namespace] = 0
-		}
-
-		rbName := rb.Name
-		if len(rb.Subjects) > 1 {
-			log.Fatalf("RoleBinding %s in Namespace %s has more that one subject", rbName, namespace)
-		}
-
-		user := rb.Subjects[0].Name
-		role := rb.RoleRef.Name
-
-		if id, exists := idMap[user]; exists {
-			cRole := strings.Replace(role, "appstudio", "konflux", 1)
-			nrbName := strings.Replace(rbName, "appstudio", "konflux", 1)
-			nrbName = strings.Replace(nrbName, user, id, 1)
-			rb.Subjects[0].Name = id
-			rb.RoleRef.Kind = "ClusterRole"
-			rb.RoleRef.Name = cRole
-			rb.Name = nrbName
-			//Cleaning metadata
-			rb.ObjectMeta.Annotations = nil
-			rb.ObjectMeta.Labels = map[string]string{"konflux-ci.dev/type": "user"}
-			rb.ObjectMeta.ResourceVersion = ""
-			rb.ObjectMeta.UID = ""
-			rb.ObjectMeta.CreationTimestamp = metav1.Time{}
-			rb.ObjectMeta.ManagedFields = nil
-			rb.APIVersion = "rbac.authorization.k8s.io/v1"
-			rb.Kind = "RoleBinding"
-			processedNamespaces[namespace]++
-			mrbList = append(mrbList, rb)
-		} else {
-			// Not adding new RoleBindings for accounts not found in corporate ldap
-			continue
-		}
-	}
-
-	count := 0
-	fmt.Printf("Searching for post-migration orphan Tenant Namespaces:\n")
-	for ns, nsCount := range processedNamespaces {
-		if nsCount == 0 {
-			fmt.Printf("%s\n", ns)
-			count++
-		}
+// reportProgress prints a "<what> done/total" counter to stderr
+func reportProgress(what string, done int, total int) {
+	if jsonLogger != nil {
+		jsonLogger.Info(what, "done", done, "total", total)
+		return
 	}
-
-	if count == 0 {
-		fmt.Printf("No orphan Tenant Namespaces found\n")
-	} else {
-		fmt.Printf("There were %d orphan Tenant Namespaces found\n", count)
-	}
-
-	return mrbList
+	fmt.Fprintf(os.Stderr, "%s %d/%d\n", what, done, total)
 }
 
-func writeMigratedRoleBindings(rbList []rbacv1.RoleBinding) {
-	file, err := os.Create(outputFile)
-	if err != nil {
-		log.Fatalf("Failed to create file: %v\n", err)
+// confirmPrune confirms the deletion of count source RoleBindings with --yes, or by prompting on a terminal
+func confirmPrune(count int) bool {
+	if assumeYes {
+		return true
 	}
 
-	defer file.Close()
-
-	scheme := runtime.NewScheme()
-	serializer := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme, scheme)
-
-	processedRBs := make(map[string]int)
-	written := 0
-
-	for _, rb := range rbList {
-		//Skip if RB already processed to avoid duplicates
-		processedRB := fmt.Sprintf("(%s-%s)", rb.Namespace, rb.Name)
-
-		if _, exists := processedRBs[processedRB]; exists {
-			fmt.Printf("RoleBinding %s for Namespace %s was already processed\n", rb.Name, rb.Namespace)
-			continue
-		}
-
-		processedRBs[processedRB] = 1
-
-		//writing separator ---
-		_, err := file.WriteString("---\n")
-		if err != nil {
-			log.Printf("Failed to write separator: %v", err)
-			continue
-		}
-
-		//writing RoleBinding
-		yamlData, err := runtime.Encode(serializer, &rb)
-		if err != nil {
-			log.Printf("Failed to encode RoleBinding %s to YAML: %v\n", rb.Name, err)
-			continue
-		}
-
-		//Removing creationTimestamp: null line
-		cYamlData := strings.Replace(string(yamlData), "metadata:\n  creationTimestamp: null", "metadata:", 1)
-
-		_, err = file.WriteString(cYamlData)
-		if err != nil {
-			log.Printf("Failed to write RoleBinding %s YAML to file: %v\n", rb.Name, err)
-			continue
-		}
-
-		written++
+	fi, err := os.Stdin.Stat()
+	if err != nil || fi.Mode()&os.ModeCharDevice == 0 {
+		logError(fmt.Sprintf("--prune would delete %d source RoleBindings, pass --yes to confirm without a terminal", count), "count", count)
+		return false
 	}
 
-	fmt.Printf("Wrote %d migrated RoleBindings to %s\n", written, outputFile)
+	fmt.Fprintf(os.Stderr, "Delete %d source RoleBindings, recorded in %s? [y/N] ", count, migrateOpts.PruneRecord)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
 }
 
-func migrate(idMap map[string]string, ctx context.Context) {
-	//Load KubeConfig
-	config, err := clientcmd.BuildConfigFromFlags("", kubeconfig)
-	if err != nil {
-		log.Fatalf("Failed to load kubeconfig: %v", err)
-	}
-
-	//Init k8s client
-	clientset, err := kubernetes.NewForConfig(config)
+// isStderrTerminal reports whether stderr is attached to a terminal
+func isStderrTerminal() bool {
+	fi, err := os.Stderr.Stat()
 	if err != nil {
-		log.Fatalf("Failed to create k8s client: %v", err)
+		return false
 	}
 
-	nsList := getTenantNamespaces(clientset, ctx)
-
-	fmt.Printf("Found %d Tenant Namespaces\n", len(nsList))
-
-	rbList := getTenantRoleBindings(clientset, ctx)
-
-	mrbList := mutateTenantRoleBindings(idMap, rbList)
-
-	writeMigratedRoleBindings(mrbList)
+	return fi.Mode()&os.ModeCharDevice != 0
 }
 
 func init() {
 	rootCmd.AddCommand(migrateCmd)
 
-	homeDir, err := os.UserHomeDir()
-
-	if err != nil {
-		log.Fatalf("Error getting home dir: %v", err)
-	}
-
-	defaultConfig := filepath.Join(homeDir, ".kube/config")
-
-	migrateCmd.Flags().StringVarP(&target, "target", "t", "user", "Select between 'email' and 'user' as the target identity attribute to use in RBAC")
-	migrateCmd.Flags().StringVarP(&outputFile, "output-file", "o", "migrated_rolebindings.yaml", "Path to output file where migrate role bindings will be written")
-	migrateCmd.Flags().StringVar(&kubeconfig, "kubeconfig", defaultConfig, "Path to the kubeconfig file")
+	defaults := migration.DefaultMigrateOptions()
+
+	migrateCmd.Flags().StringVarP(&migrateOpts.Target, "target", "t", defaults.Target, "Select between 'email' and 'user' as the target identity attribute to use in RBAC")
+	migrateCmd.Flags().StringVarP(&migrateOpts.OutputFile, "output-file", "o", defaults.OutputFile, "Path to output file where migrate role bindings will be written, - for stdout. May embed {{.Date}}, {{.Time}}, {{.Target}} and {{.Context}}")
+	migrateCmd.Flags().StringVar(&migrateOpts.NonUserSubjects, "non-user-subjects", defaults.NonUserSubjects, "Select between 'keep' and 'skip' for RoleBindings whose subject is a Group or ServiceAccount")
+	migrateCmd.Flags().StringVar(&migrateOpts.SubjectKind, "subject-kind", defaults.SubjectKind, "Subject Kind to set on migrated RoleBindings for sso users, User or Group")
+	migrateCmd.Flags().StringVar(&migrateOpts.SubjectAPIGroup, "subject-apigroup", defaults.SubjectAPIGroup, "Subject APIGroup to set on migrated RoleBindings for sso users: rbac.authorization.k8s.io, or user.openshift.io or empty for legacy OpenShift authorization")
+	migrateCmd.Flags().StringSliceVarP(&migrateOpts.Namespaces, "namespace", "n", nil, "Restrict the migration to the given Tenant Namespace, can be repeated")
+	migrateCmd.Flags().BoolVar(&showProgress, "progress", false, "Print progress counters to stderr, enabled by default when stderr is a terminal")
+	migrateCmd.Flags().StringVar(&migrateOpts.MetricsFile, "metrics-file", "", "Path to a file where Prometheus textfile-format metrics of the run will be written")
+	migrateCmd.Flags().StringSliceVar(&migrateOpts.UserAccountNamespaces, "useraccount-namespace", defaults.UserAccountNamespaces, "Namespaces where the toolchain UserAccounts are listed from, repeat or comma separate it for several member namespaces")
+	migrateCmd.Flags().BoolVarP(&migrateOpts.AllNamespaces, "all-namespaces", "A", false, "List UserAccounts across all namespaces, ignored when --useraccount-namespace is set")
+	migrateCmd.Flags().StringVar(&migrateOpts.OnlyUsers, "only-users", "", "Only migrate User subjects whose sso id or kubesaw name is listed, as a comma separated list or a file with one id per line")
+	migrateCmd.Flags().BoolVar(&migrateOpts.ExpandGroups, "expand-groups", false, "Expand OpenShift Group subjects into one migrated RoleBinding per member user")
+	migrateCmd.Flags().BoolVar(&migrateOpts.Apply, "apply", false, "Create the migrated RoleBindings on the cluster, updating the subjects of existing ones, in addition to writing --output-file")
+	migrateCmd.Flags().BoolVar(&migrateOpts.DryRun, "dry-run", false, "Send the --apply requests as server-side dry runs, --output-file is still written")
+	migrateCmd.Flags().StringVar(&migrateOpts.RoleFilter, "role-filter", "", "Only migrate RoleBindings whose source role matches this glob, or regular expression when prefixed with 'regex:'")
+	migrateCmd.Flags().StringVar(&migrateOpts.ExtraSelector, "extra-rolebinding-selector", "", "Label selector AND-combined with the Tenant RoleBinding one when listing them, e.g. env=prod. Ignored with --input-file")
+	migrateCmd.Flags().BoolVar(&migrateOpts.EffectiveBindingsOnly, "print-effective-bindings-only", false, "Print a namespace, name, subject and role table of the migrated RoleBindings to stdout for audit, without writing any output or applying")
+	migrateCmd.Flags().StringVar(&migrateOpts.MappingReport, "mapping-report", "", "Path to a CSV (or JSON with a .json extension) file recording each source to migrated RoleBinding mapping")
+	migrateCmd.Flags().StringVar(&migrateOpts.CreatedAfter, "created-after", "", "Only migrate RoleBindings created after this RFC3339 time (e.g. 2025-01-31T00:00:00Z), read from the live object")
+	migrateCmd.Flags().StringVar(&migrateOpts.OutputFormat, "output-format", defaults.OutputFormat, "Select between 'yaml' manifests, 'ssa' manifests ready for kubectl apply --server-side, a 'helm' values list of namespace, user and role, 'jsonl' one JSON object per line and a 'script' of kubectl apply calls against the current context")
+	migrateCmd.Flags().BoolVar(&migrateOpts.OutputHeader, "output-header", defaults.OutputHeader, "Start the yaml, ssa and script outputs with a comment block of the tool version, time, context, selection and account count, --output-header=false to omit it")
+	migrateCmd.Flags().BoolVar(&migrateOpts.LowercaseIDs, "lowercase-ids", false, "Lowercase the resolved sso ids so RoleBinding names and subjects are consistent")
+	migrateCmd.Flags().IntVar(&migrateOpts.MaxRoleBindings, "max-rolebindings", defaults.MaxRoleBindings, "Abort when more Tenant RoleBindings than this are found, 0 for unlimited")
+	migrateCmd.Flags().BoolVar(&migrateOpts.Force, "force", false, "Proceed past the safety guardrails such as --max-rolebindings and overwriting an existing --output-file")
+	migrateCmd.Flags().StringToStringVar(&migrateOpts.TargetOverrides, "target-override", nil, "Per-account target or --transform name overriding --target, as account-name=email or account-name=user, can be repeated")
+	migrateCmd.Flags().StringToStringVar(&migrateOpts.MapOverrides, "map-override", nil, "Fixed sso id of an account, e.g. a bot missing from LDAP, as account-name=id without resolving it, or account-name= to exclude it. Can be repeated")
+	migrateCmd.Flags().StringVar(&migrateOpts.NameTemplate, "name-template", defaults.NameTemplate, "Go template for migrated RoleBinding names, with .Name .Namespace .Subject .Id .Role .SourceRole and the replace/lower functions")
+	migrateCmd.Flags().StringVar(&migrateOpts.EmailClaim, "email-claim", defaults.EmailClaim, "UserAccount propagatedClaims key holding the email, e.g. emailAddress or userEmail on other toolchain versions")
+	migrateCmd.Flags().IntVar(&migrateOpts.ListMaxAttempts, "list-max-attempts", defaults.ListMaxAttempts, "Attempts of each k8s List call on throttling or transient API server errors, with exponential backoff")
+	migrateCmd.Flags().StringVar(&migrateOpts.RoleMap, "role-map", "", "Path to a YAML file listing source role to target role entries, optionally per namespace and of kind Role")
+	migrateCmd.Flags().StringVar(&migrateOpts.ValidateRoles, "validate-roles", defaults.ValidateRoles, "Select between 'off', 'warn' and 'error' for migrated RoleBindings referencing a target role missing on the cluster")
+	migrateCmd.Flags().StringVar(&migrateOpts.InputFile, "input-file", "", "Path to a YAML or JSON file of source RoleBindings to migrate instead of listing them from the cluster")
+	migrateCmd.Flags().StringVar(&migrateOpts.IDMapFile, "id-map-file", "", "Path to a YAML or JSON map of kubesaw account name to sso id used instead of resolving the UserAccounts")
+	migrateCmd.Flags().StringVar(&migrateOpts.NamespaceReport, "namespace-report", "", "Path to a CSV (or JSON with a .json extension) file with the migrated and skipped RoleBindings per Tenant Namespace")
+	migrateCmd.Flags().StringVar(&migrateOpts.OrphanReport, "orphan-report", "", "Path to a file listing the orphan Tenant Namespaces with the reason no-bindings or none-migrated, as JSON with a .json extension or one tab-separated line each")
+	migrateCmd.Flags().DurationVar(&timeout, "timeout", 0, "Cancel the whole run when it takes longer than this duration (e.g. 30m), 0 for no timeout")
+	migrateCmd.Flags().BoolVar(&migrateOpts.Append, "append", false, "Append the migrated RoleBindings to an existing --output-file instead of refusing to overwrite it")
+	migrateCmd.Flags().StringSliceVar(&migrateOpts.SubjectClaims, "subject-claim", nil, "UserAccount propagatedClaims key (e.g. sub or preferred_username) also matched against RoleBinding subjects after the UserAccount name, can be repeated and is tried in order")
+	migrateCmd.Flags().StringVar(&migrateOpts.OutputURL, "output-url", "", "Upload the output to a file://, https:// (PUT, e.g. pre-signed) or s3://bucket/key URL instead of writing --output-file")
+	migrateCmd.Flags().StringArrayVar(&migrateOpts.ExcludeSubjectRegex, "exclude-subject-regex", nil, "Skip RoleBindings whose subject name matches this regular expression (e.g. '-bot$' or '^system:'), can be repeated")
+	migrateCmd.Flags().IntVar(&migrateOpts.NSConcurrency, "ns-concurrency", defaults.NSConcurrency, "Number of Tenant Namespaces whose RoleBindings are listed and migrated in parallel, above 1 the RoleBindings are listed per Namespace")
+	migrateCmd.Flags().BoolVar(&migrateOpts.KeepGoing, "keep-going", false, "With --apply, keep applying past RoleBindings failing to apply, reporting all the failures at the end")
+	migrateCmd.Flags().StringToStringVar(&migrateOpts.OwnerRef, "owner-ref", nil, "Owner set on every migrated RoleBinding, as apiVersion=...,kind=...,name=...,uid=...")
+	migrateCmd.Flags().BoolVar(&migrateOpts.Diff, "diff", false, "With --dry-run, print a unified diff between the YAML of each source RoleBinding and of its migrated RoleBinding")
+	migrateCmd.Flags().StringVar(&migrateOpts.UnmappedRoles, "unmapped-roles", defaults.UnmappedRoles, "Select 'warn' or 'skip' for RoleBindings to a ClusterRole not matching --role-transform and with no --role-map entry")
+	migrateCmd.Flags().BoolVar(&migrateOpts.Prune, "prune", false, "With --apply, delete each source RoleBinding once all its migrated RoleBindings applied")
+	migrateCmd.Flags().StringVar(&migrateOpts.PruneRecord, "prune-record", defaults.PruneRecord, "File recording the source RoleBindings deleted by --prune, restore them with kubectl apply -f")
+	migrateCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Confirm --prune without prompting")
+	migrateCmd.Flags().StringVar(&migrateOpts.EmailCleanRegex, "email-clean-regex", defaults.EmailCleanRegex, "Regular expression matching the subaddress of the emails, replaced with --email-clean-replace before resolving them")
+	migrateCmd.Flags().StringVar(&migrateOpts.EmailCleanReplace, "email-clean-replace", defaults.EmailCleanReplace, "Replacement of the --email-clean-regex matches, may reference groups as ${1}")
+	migrateCmd.Flags().Float64Var(&migrateOpts.SamplePercent, "sample-percent", defaults.SamplePercent, "Only migrate this percentage of the RoleBindings for a canary run, the same ones for the same --sample-seed")
+	migrateCmd.Flags().BoolVar(&migrateOpts.RequireAccounts, "require-accounts", defaults.RequireAccounts, "Fail when no UserAccount is found instead of warning and migrating nothing")
+	migrateCmd.Flags().BoolVar(&migrateOpts.ReplaceAll, "replace-all", defaults.ReplaceAll, "Rename every appstudio token in the RoleBinding and ClusterRole names instead of the first one")
+	migrateCmd.Flags().StringVar(&migrateOpts.Source, "source", defaults.Source, "Select 'useraccount' to resolve the member UserAccounts or 'spacebinding' to derive the RoleBindings from the host SpaceBindings and resolve their MasterUserRecords")
+	migrateCmd.Flags().StringVar(&migrateOpts.SpaceBindingNamespace, "spacebinding-namespace", defaults.SpaceBindingNamespace, "Namespace of the host cluster where the SpaceBindings and MasterUserRecords are listed from with --source spacebinding")
+	migrateCmd.Flags().StringVar(&migrateOpts.HostKubeconfig, "host-kubeconfig", defaults.HostKubeconfig, "Path to the kubeconfig of the host cluster for --source spacebinding, --kubeconfig when unset")
+	migrateCmd.Flags().IntVar(&migrateOpts.PreviewCount, "preview-count", defaults.PreviewCount, "Only print the dry run detail of the first N RoleBindings, 0 for all. The summary still counts all of them")
+	migrateCmd.Flags().StringVar(&migrateOpts.Transform, "transform", defaults.Transform, fmt.Sprintf("Named transform resolving the ids instead of --target, one of %s or claim:<name> for the value of another UserAccount claim", strings.Join(migration.TransformNames(), ", ")))
+	migrateCmd.Flags().StringVar(&migrateOpts.OutputConfigMap, "output-configmap", defaults.OutputConfigMap, "Also store the output in this namespace/name ConfigMap, created or updated, honoring --dry-run")
+	migrateCmd.Flags().StringVar(&migrateOpts.MatchBy, "match-by", defaults.MatchBy, "Select 'name' to match the RoleBinding subjects to the UserAccount names or 'email' to match subjects holding emails to the account emails")
+	migrateCmd.Flags().BoolVar(&migrateOpts.RequireRoleBindings, "require-rolebindings", defaults.RequireRoleBindings, "Fail when no Tenant RoleBinding matches the selection instead of warning and migrating nothing")
+	migrateCmd.Flags().BoolVar(&migrateOpts.ValidateIdentities, "validate-identities", defaults.ValidateIdentities, "Warn about the migrated User subjects that do not exist as OpenShift users on the cluster")
+	migrateCmd.Flags().StringVar(&migrateOpts.OutputDir, "output-dir", defaults.OutputDir, "Directory of the --output-by-role files")
+	migrateCmd.Flags().BoolVar(&migrateOpts.OutputByRole, "output-by-role", defaults.OutputByRole, "Write one file per target role in --output-dir, e.g. konflux-admin-user-actions.yaml, instead of --output-file")
+	migrateCmd.Flags().StringVar(&migrateOpts.FailOnUnresolved, "fail-on-unresolved", defaults.FailOnUnresolved, "Abort before writing or applying anything when more accounts than this count, or percentage with a % suffix (e.g. 5%), are unresolved. Disabled when empty")
+	migrateCmd.Flags().BoolVar(&migrateOpts.StrictSingleSubject, "strict-single-subject", defaults.StrictSingleSubject, "Fail on a RoleBinding with several subjects instead of splitting it into one migrated RoleBinding per subject")
+	migrateCmd.Flags().BoolVar(&migrateOpts.Watch, "watch", false, "With --apply, keep migrating and applying the Tenant RoleBindings created after the run until interrupted")
+	migrateCmd.Flags().DurationVar(&migrateOpts.WatchRefresh, "watch-refresh", defaults.WatchRefresh, "Interval between two resolutions of the account ids during --watch, 0 to never refresh them")
+	migrateCmd.Flags().StringVar(&migrateOpts.Checkpoint, "checkpoint", "", "Path to a JSON file recording the resolved accounts and applied RoleBindings, a restarted run resumes from it instead of resolving and applying them again")
+	migrateCmd.Flags().StringVar(&migrateOpts.RoleTransform, "role-transform", defaults.RoleTransform, "Rename of the ClusterRoles as 'regex => template', applied to the first match and may reference groups as ${1}")
+	migrateCmd.Flags().Int64Var(&migrateOpts.SampleSeed, "sample-seed", defaults.SampleSeed, "Seed selecting the --sample-percent RoleBindings, change it to canary another subset")
+	migrateCmd.Flags().StringArrayVar(&migrateOpts.KeepAnnotationPrefixes, "keep-annotation-prefix", nil, "Keep the source annotations whose key starts with this prefix on the migrated RoleBindings, can be repeated. All annotations are dropped by default")
+	migrateCmd.Flags().BoolVarP(&migrateOpts.Verbose, "verbose", "v", false, "Print detailed information about the run")
+	addKubeFlags(migrateCmd.Flags(), migrateOpts)
+	addLDAPFlags(migrateCmd.Flags(), &migrateOpts.LDAP)
+	addIdentityFlags(migrateCmd.Flags(), &migrateOpts.Identity)
+
+	//config prints the effective values of the same flags
+	configCmd.Flags().AddFlagSet(migrateCmd.Flags())
 }
@@ -5,11 +5,21 @@ Copyright © 2025 Red Hat, Inc.
 package cmd
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
+var cfgFile string
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "wscli",
@@ -22,21 +32,99 @@ wscli migrate -t user/email
 Where -t is the identity atribute (username or email) that will be targeted.
 
 This tools assumes you have already authenticated with Red Hat SSO and with the
-target kubesaw member cluster`,
+target kubesaw member cluster.
+
+Every flag can also be set through a WSCLI_ prefixed environment variable
+(e.g. WSCLI_OUTPUT_FILE) or through the config file passed with --config,
+using the flag name as key. Precedence is flags > env > config file > defaults.`,
+	// Errors are printed by Execute along with selecting the exit code
+	SilenceErrors: true,
 	// Uncomment the following line if your bare application
 	// has an action associated with it:
 	// Run: func(cmd *cobra.Command, args []string) { },
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := bindConfig(cmd); err != nil {
+			return err
+		}
+
+		return setupLogging(logFormat)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
 // This is called by main.main(). It only needs to happen once to the rootCmd.
+// Interrupting the process cancels the command context so in-flight LDAP and k8s calls stop cleanly.
 func Execute() {
-	err := rootCmd.Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := rootCmd.ExecuteContext(ctx)
+	stop()
 	if err != nil {
-		os.Exit(1)
+		code := exitFailed
+		var exitErr *exitError
+		if errors.As(err, &exitErr) {
+			code = exitErr.code
+		}
+		if exitErr == nil || exitErr.err != nil {
+			logError(err.Error(), "error", err)
+		}
+		os.Exit(code)
+	}
+}
+
+// bindConfig loads the config file and environment and sets every flag of cmd that
+// was not explicitly passed on the command line from them
+func bindConfig(cmd *cobra.Command) error {
+	v := viper.New()
+	v.SetEnvPrefix("wscli")
+	v.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
+	v.AutomaticEnv()
+
+	if cfgFile != "" {
+		v.SetConfigFile(cfgFile)
+		if err := v.ReadInConfig(); err != nil {
+			return fmt.Errorf("failed to read config file %s: %w", cfgFile, err)
+		}
 	}
+
+	var bindErr error
+	cmd.Flags().VisitAll(func(f *pflag.Flag) {
+		if bindErr != nil || f.Changed || f.Name == "config" || !v.IsSet(f.Name) {
+			return
+		}
+
+		//Replace keeps the items whole, joining them would split the ones holding a comma
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			items := v.GetStringSlice(f.Name)
+			if err := sv.Replace(items); err != nil {
+				bindErr = fmt.Errorf("invalid value %q for %s: %w", items, f.Name, err)
+				return
+			}
+			f.Changed = true
+			return
+		}
+
+		var value string
+		if m, ok := v.Get(f.Name).(map[string]interface{}); ok {
+			pairs := make([]string, 0, len(m))
+			for key, val := range m {
+				pairs = append(pairs, fmt.Sprintf("%s=%v", key, val))
+			}
+			value = strings.Join(pairs, ",")
+		} else {
+			value = v.GetString(f.Name)
+		}
+
+		if err := cmd.Flags().Set(f.Name, value); err != nil {
+			bindErr = fmt.Errorf("invalid value %q for %s: %w", value, f.Name, err)
+		}
+	})
+
+	return bindErr
 }
 
 func init() {
 	// Here you will define your flags and configuration settings at root command.
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Select between 'text' and 'json' for status and error output, json lines are written to stderr")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only print errors, the output file and the json summary are still written")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Path to a config file (e.g. wscli.yaml) holding flag values keyed by flag name")
 }
@@ -0,0 +1,124 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/konflux-workspaces/rbac-migration/pkg/migration"
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// printSummary prints the counters of a migrate run
+func printSummary(s migration.MigrationStats) {
+	if jsonLogger != nil {
+		jsonLogger.Info("Migration summary",
+			"accounts_total", s.AccountsTotal,
+			"accounts_resolved", s.AccountsResolved,
+			"rolebindings_mutated", s.RoleBindingsMutated,
+			"rolebindings_skipped", s.RoleBindingsSkipped,
+			"rolebindings_already_migrated", s.AlreadyMigrated,
+			"orphan_namespaces", s.OrphanNamespaces,
+			"rolebindings_written", s.RoleBindingsWritten,
+			"rolebindings_created", s.RoleBindingsCreated,
+			"rolebindings_updated", s.RoleBindingsUpdated,
+			"rolebindings_unchanged", s.RoleBindingsUnchanged,
+			"rolebindings_failed", s.RoleBindingsFailed,
+			"rolebindings_pruned", s.RoleBindingsPruned,
+			"diff_lines_added", s.DiffLinesAdded,
+			"diff_lines_removed", s.DiffLinesRemoved,
+			"ldap_cache_hits", s.LDAPCacheHits,
+			"ldap_negative_cache_hits", s.LDAPNegativeCacheHits,
+		)
+		return
+	}
+
+	if quiet {
+		return
+	}
+
+	fmt.Fprintf(statusOut, "Migration summary:\n")
+	fmt.Fprintf(statusOut, "  Accounts found:         %d\n", s.AccountsTotal)
+	fmt.Fprintf(statusOut, "  Accounts resolved:      %d\n", s.AccountsResolved)
+	fmt.Fprintf(statusOut, "  RoleBindings migrated:  %d\n", s.RoleBindingsMutated)
+	fmt.Fprintf(statusOut, "  RoleBindings skipped:   %d\n", s.RoleBindingsSkipped)
+	fmt.Fprintf(statusOut, "  Already migrated:       %d\n", s.AlreadyMigrated)
+	fmt.Fprintf(statusOut, "  Orphan Namespaces:      %d\n", s.OrphanNamespaces)
+	fmt.Fprintf(statusOut, "  RoleBindings written:   %d\n", s.RoleBindingsWritten)
+	if s.LDAPCacheHits+s.LDAPNegativeCacheHits > 0 {
+		fmt.Fprintf(statusOut, "  LDAP cache hits:        %d found, %d not found\n", s.LDAPCacheHits, s.LDAPNegativeCacheHits)
+	}
+	if migrateOpts.Apply {
+		dryRun := ""
+		if migrateOpts.DryRun {
+			dryRun = " (dry run)"
+		}
+		fmt.Fprintf(statusOut, "  RoleBindings applied:   %d created, %d updated, %d unchanged, %d failed%s\n", s.RoleBindingsCreated, s.RoleBindingsUpdated, s.RoleBindingsUnchanged, s.RoleBindingsFailed, dryRun)
+	}
+	if migrateOpts.Prune {
+		fmt.Fprintf(statusOut, "  Sources pruned:         %d\n", s.RoleBindingsPruned)
+	}
+	if migrateOpts.Diff {
+		fmt.Fprintf(statusOut, "  Diff lines:             %d added, %d removed\n", s.DiffLinesAdded, s.DiffLinesRemoved)
+	}
+}
+
+// printNamespaceSummaries prints a table of the migrated and skipped RoleBindings per Tenant Namespace
+func printNamespaceSummaries(summaries []migration.NamespaceSummary) {
+	if jsonLogger != nil {
+		for _, s := range summaries {
+			jsonLogger.Info("Namespace summary",
+				"namespace", s.Namespace,
+				"source", s.Source,
+				"migrated", s.Migrated,
+				"already_migrated", s.AlreadyMigrated,
+				"skipped", s.SkippedTotal(),
+				"skip_reasons", s.Skipped,
+			)
+		}
+		return
+	}
+
+	if quiet || len(summaries) == 0 {
+		return
+	}
+
+	w := tabwriter.NewWriter(statusOut, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tSOURCE\tMIGRATED\tALREADY MIGRATED\tSKIPPED\tREASONS")
+	for _, s := range summaries {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%d\t%s\n", s.Namespace, s.Source, s.Migrated, s.AlreadyMigrated, s.SkippedTotal(), s.SkipReasons())
+	}
+	w.Flush()
+}
+
+// printDiffs prints the unified diff of each migrated RoleBinding, they were asked for so --quiet keeps them
+func printDiffs(diffs []migration.RoleBindingDiff) {
+	if more := len(diffs) - migrateOpts.PreviewCount; migrateOpts.PreviewCount > 0 && more > 0 {
+		defer fmt.Fprintf(statusOut, "... and %d more\n", more)
+		diffs = diffs[:migrateOpts.PreviewCount]
+	}
+	for _, d := range diffs {
+		if jsonLogger != nil {
+			jsonLogger.Info("RoleBinding diff", "namespace", d.Namespace, "name", d.Name, "added", d.Added, "removed", d.Removed, "diff", d.Diff)
+			continue
+		}
+		fmt.Fprint(statusOut, d.Diff)
+	}
+}
+
+// printEffectiveBindings prints a table of the migrated RoleBindings to stdout, one line each in Namespace then
+// name order so it can be grepped and sorted. It is the requested output so --quiet keeps it
+func printEffectiveBindings(rbList []rbacv1.RoleBinding) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tNAME\tSUBJECT\tROLE")
+	for _, rb := range rbList {
+		for _, subject := range rb.Subjects {
+			fmt.Fprintf(w, "%s\t%s\t%s/%s\t%s/%s\n", rb.Namespace, rb.Name, subject.Kind, subject.Name, rb.RoleRef.Kind, rb.RoleRef.Name)
+		}
+	}
+	w.Flush()
+}
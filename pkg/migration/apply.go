@@ -0,0 +1,151 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// applyAction is the outcome of applying a single migrated RoleBinding
+type applyAction string
+
+const (
+	actionCreated   applyAction = "created"
+	actionUpdated   applyAction = "updated"
+	actionUnchanged applyAction = "unchanged"
+)
+
+// ErrApplyFailed is wrapped by the error Run returns when --keep-going collected RoleBindings failing to apply
+var ErrApplyFailed = errors.New("failed to apply RoleBindings")
+
+// applyRoleBindings creates the migrated RoleBindings on the cluster, updating the ones that already exist.
+// It stops at the first RoleBinding failing to apply, or with --keep-going applies the rest and returns all
+// the failures along with the namespace/name of the failed RoleBindings. With --dry-run the requests are
+// only validated by the API server
+func applyRoleBindings(clientset kubernetes.Interface, rbList []rbacv1.RoleBinding, opts *MigrateOptions, stats *MigrationStats, ctx context.Context) (map[string]bool, error) {
+	counts := make(map[applyAction]int)
+	var failures []error
+	failed := make(map[string]bool)
+	applied := 0
+	checkpointed := 0
+
+	for _, rb := range rbList {
+		//Applied before a restart, the dry runs are never recorded
+		if !opts.DryRun && opts.checkpoint.isApplied(rb.Namespace, rb.Name) {
+			checkpointed++
+			continue
+		}
+
+		action, err := applyRoleBinding(clientset, &rb, opts.DryRun, ctx)
+		if err != nil {
+			if err := interrupted(ctx); err != nil {
+				return nil, err
+			}
+			err = fmt.Errorf("failed to apply RoleBinding %s in Namespace %s: %w", rb.Name, rb.Namespace, err)
+			if !opts.KeepGoing {
+				return nil, err
+			}
+			opts.logError(err.Error(), "namespace", rb.Namespace, "name", rb.Name, "error", err)
+			failures = append(failures, err)
+			failed[rb.Namespace+"/"+rb.Name] = true
+			continue
+		}
+
+		if opts.previewed(applied) {
+			opts.logInfo(fmt.Sprintf("RoleBinding %s in Namespace %s %s", rb.Name, rb.Namespace, action), "namespace", rb.Namespace, "name", rb.Name, "action", string(action))
+		}
+		applied++
+		counts[action]++
+		if !opts.DryRun {
+			if err := opts.checkpoint.recordApplied(rb.Namespace, rb.Name); err != nil {
+				return nil, fmt.Errorf("failed to save --checkpoint: %w", err)
+			}
+		}
+	}
+	if checkpointed > 0 {
+		opts.logInfo(fmt.Sprintf("Skipped %d RoleBindings already applied according to --checkpoint %s", checkpointed, opts.Checkpoint), "count", checkpointed, "file", opts.Checkpoint)
+	}
+	if hidden := applied - opts.PreviewCount; opts.DryRun && opts.PreviewCount > 0 && hidden > 0 {
+		opts.logInfo(fmt.Sprintf("... and %d more", hidden), "more", hidden)
+	}
+
+	stats.RoleBindingsCreated = counts[actionCreated]
+	stats.RoleBindingsUpdated = counts[actionUpdated]
+	stats.RoleBindingsUnchanged = counts[actionUnchanged]
+	stats.RoleBindingsFailed = len(failures)
+
+	verb := "Applied"
+	if opts.DryRun {
+		verb = "Dry run applied"
+	}
+	opts.logInfo(fmt.Sprintf("%s %d RoleBindings: %d created, %d updated, %d unchanged, %d failed", verb, len(rbList), counts[actionCreated], counts[actionUpdated], counts[actionUnchanged], len(failures)),
+		"created", counts[actionCreated], "updated", counts[actionUpdated], "unchanged", counts[actionUnchanged], "failed", len(failures), "dry_run", opts.DryRun)
+
+	if len(failures) > 0 {
+		return failed, fmt.Errorf("%w: %d of %d failed:\n%w", ErrApplyFailed, len(failures), len(rbList), errors.Join(failures...))
+	}
+
+	return failed, nil
+}
+
+// previewed reports whether the dry run detail of the i-th RoleBinding is within --preview-count
+func (o *MigrateOptions) previewed(i int) bool {
+	return !o.DryRun || o.PreviewCount == 0 || i < o.PreviewCount
+}
+
+// applyRoleBinding creates rb, or when it already exists updates its subjects and labels, retrying on conflicts
+// with concurrent writers. RoleRef is immutable so an existing binding to a different role is an error
+func applyRoleBinding(clientset kubernetes.Interface, rb *rbacv1.RoleBinding, dryRun bool, ctx context.Context) (applyAction, error) {
+	client := clientset.RbacV1().RoleBindings(rb.Namespace)
+
+	var dryRunOpt []string
+	if dryRun {
+		dryRunOpt = []string{metav1.DryRunAll}
+	}
+
+	_, err := client.Create(ctx, rb, metav1.CreateOptions{DryRun: dryRunOpt, FieldManager: FieldManager})
+	if err == nil {
+		return actionCreated, nil
+	}
+	if !apierrors.IsAlreadyExists(err) {
+		return "", err
+	}
+
+	action := actionUnchanged
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current, err := client.Get(ctx, rb.Name, metav1.GetOptions{})
+		if err != nil {
+			return err
+		}
+
+		if !equality.Semantic.DeepEqual(current.RoleRef, rb.RoleRef) {
+			return fmt.Errorf("existing RoleBinding references %s %s, roleRef is immutable", current.RoleRef.Kind, current.RoleRef.Name)
+		}
+
+		if equality.Semantic.DeepEqual(current.Subjects, rb.Subjects) && equality.Semantic.DeepEqual(current.Labels, rb.Labels) {
+			action = actionUnchanged
+			return nil
+		}
+
+		current.Subjects = rb.Subjects
+		current.Labels = rb.Labels
+		_, err = client.Update(ctx, current, metav1.UpdateOptions{DryRun: dryRunOpt, FieldManager: FieldManager})
+		if err == nil {
+			action = actionUpdated
+		}
+		return err
+	})
+
+	return action, err
+}
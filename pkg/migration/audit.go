@@ -0,0 +1,69 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"encoding/json"
+	"os"
+	"time"
+)
+
+// auditLog appends a JSON line per LDAP search to the --ldap-audit-file, a nil auditLog records nothing
+type auditLog struct {
+	file    *os.File
+	encoder *json.Encoder
+}
+
+// auditEntry is the record of a single LDAP search, the search holds no credentials to leak
+type auditEntry struct {
+	Time      time.Time `json:"time"`
+	Email     string    `json:"email"`
+	Attribute string    `json:"attribute"`
+	UID       string    `json:"uid"`
+	Matches   int       `json:"matches"`
+	Error     string    `json:"error,omitempty"`
+}
+
+// openAuditLog opens path for appending, returning a nil auditLog when path is empty
+func openAuditLog(path string) (*auditLog, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	return &auditLog{file: file, encoder: json.NewEncoder(file)}, nil
+}
+
+// record appends the search of email by attribute, resolved to uid among matches entries or failed with searchErr
+func (a *auditLog) record(email string, attribute string, uid string, matches int, searchErr error) error {
+	if a == nil {
+		return nil
+	}
+
+	entry := auditEntry{
+		Time:      time.Now().UTC(),
+		Email:     email,
+		Attribute: attribute,
+		UID:       uid,
+		Matches:   matches,
+	}
+	if searchErr != nil {
+		entry.Error = searchErr.Error()
+	}
+
+	return a.encoder.Encode(entry)
+}
+
+// Close closes the audit file
+func (a *auditLog) Close() error {
+	if a == nil {
+		return nil
+	}
+	return a.file.Close()
+}
@@ -0,0 +1,146 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+)
+
+// checkpoint records the progress of a run in the --checkpoint file so that a restarted run skips the
+// accounts already resolved and the RoleBindings already applied. A nil checkpoint records nothing
+type checkpoint struct {
+	path    string
+	data    checkpointData
+	applied map[string]bool
+	pending int // records not saved yet
+}
+
+// checkpointData is the JSON content of the --checkpoint file
+type checkpointData struct {
+	// Accounts maps the target/name of each resolved UserAccount to its id, the unresolved ones are not recorded
+	Accounts map[string]string `json:"accounts"`
+	// Applied lists the namespace/name of the migrated RoleBindings applied to the cluster
+	Applied []string `json:"applied"`
+}
+
+// loadCheckpoint reads the --checkpoint file at path, starting an empty one when it does not exist yet.
+// It returns a nil checkpoint when path is empty
+func loadCheckpoint(path string) (*checkpoint, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	c := &checkpoint{path: path, data: checkpointData{Accounts: make(map[string]string)}, applied: make(map[string]bool)}
+
+	content, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(content, &c.data); err != nil {
+		return nil, err
+	}
+	if c.data.Accounts == nil {
+		c.data.Accounts = make(map[string]string)
+	}
+	for _, key := range c.data.Applied {
+		c.applied[key] = true
+	}
+
+	return c, nil
+}
+
+// account returns the id the UserAccount name resolved to with target in a previous run
+func (c *checkpoint) account(target string, name string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	id, ok := c.data.Accounts[target+"/"+name]
+	return id, ok && id != ""
+}
+
+// recordAccount records the id the UserAccount name resolved to with target, saving the file every
+// progressInterval records. An empty id is a failure retried by the next run and is not recorded
+func (c *checkpoint) recordAccount(target string, name string, id string) error {
+	if c == nil || id == "" {
+		return nil
+	}
+	c.data.Accounts[target+"/"+name] = id
+	return c.recorded()
+}
+
+// forgetAccounts drops the recorded ids, the accounts are resolved and recorded again
+func (c *checkpoint) forgetAccounts() {
+	if c == nil {
+		return
+	}
+	c.data.Accounts = make(map[string]string)
+}
+
+// isApplied reports whether the RoleBinding namespace/name was recorded as applied
+func (c *checkpoint) isApplied(namespace string, name string) bool {
+	return c != nil && c.applied[namespace+"/"+name]
+}
+
+// recordApplied records the RoleBinding namespace/name as applied, saving the file every progressInterval records
+func (c *checkpoint) recordApplied(namespace string, name string) error {
+	if c == nil {
+		return nil
+	}
+	key := namespace + "/" + name
+	if !c.applied[key] {
+		c.applied[key] = true
+		c.data.Applied = append(c.data.Applied, key)
+	}
+	return c.recorded()
+}
+
+// recorded counts a new record, saving the file once progressInterval of them are pending
+func (c *checkpoint) recorded() error {
+	c.pending++
+	if c.pending < progressInterval {
+		return nil
+	}
+	return c.save()
+}
+
+// save writes the checkpoint to a temporary file renamed over the --checkpoint file, so an interruption
+// never leaves it half written
+func (c *checkpoint) save() error {
+	if c == nil {
+		return nil
+	}
+
+	content, err := json.MarshalIndent(c.data, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(c.path), filepath.Base(c.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), c.path); err != nil {
+		return err
+	}
+
+	c.pending = 0
+	return nil
+}
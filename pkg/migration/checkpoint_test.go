@@ -0,0 +1,42 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointAccounts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "migrate.checkpoint.json")
+	c, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint() failed: %v", err)
+	}
+
+	if err := c.recordAccount("ldap-uid", "alice", "asmith"); err != nil {
+		t.Fatalf("recordAccount() failed: %v", err)
+	}
+	if err := c.recordAccount("ldap-uid", "gone", ""); err != nil {
+		t.Fatalf("recordAccount() failed: %v", err)
+	}
+	if err := c.save(); err != nil {
+		t.Fatalf("save() failed: %v", err)
+	}
+
+	resumed, err := loadCheckpoint(path)
+	if err != nil {
+		t.Fatalf("loadCheckpoint() failed: %v", err)
+	}
+	if id, ok := resumed.account("ldap-uid", "alice"); !ok || id != "asmith" {
+		t.Errorf("account(ldap-uid, alice) = %q, %v, want asmith", id, ok)
+	}
+	if _, ok := resumed.account("email", "alice"); ok {
+		t.Error("alice is checkpointed for the email target, want only the ldap-uid one")
+	}
+	if _, ok := resumed.account("ldap-uid", "gone"); ok {
+		t.Error("the unresolved account gone is checkpointed, want it looked up again")
+	}
+}
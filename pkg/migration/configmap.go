@@ -0,0 +1,86 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/util/retry"
+)
+
+// maxConfigMapSize is the size limit the API server enforces on the data of a ConfigMap
+const maxConfigMapSize = 1024 * 1024
+
+// parseConfigMapRef splits an --output-configmap namespace/name reference
+func parseConfigMapRef(ref string) (string, string, error) {
+	namespace, name, ok := strings.Cut(ref, "/")
+	if !ok || namespace == "" || name == "" || strings.Contains(name, "/") {
+		return "", "", fmt.Errorf("expected namespace/name, got %q", ref)
+	}
+	return namespace, name, nil
+}
+
+// configMapKey returns the data key holding the migrated RoleBindings in the selected --output-format
+func configMapKey(opts *MigrateOptions) string {
+	return "migrated_rolebindings." + outputFormats[opts.OutputFormat].ext
+}
+
+// writeMigratedConfigMap renders rbList in the selected --output-format and stores it under its data key
+// in the --output-configmap ConfigMap, creating it or updating that key and leaving the others as they are
+func writeMigratedConfigMap(clientset kubernetes.Interface, rbList []rbacv1.RoleBinding, opts *MigrateOptions, ctx context.Context) (int, error) {
+	var buf bytes.Buffer
+	written, err := renderRoleBindings(&buf, rbList, opts, true)
+	if err != nil {
+		return written, err
+	}
+	if buf.Len() > maxConfigMapSize {
+		return 0, fmt.Errorf("migrated RoleBindings take %d bytes, more than the %d a ConfigMap holds. Narrow the selection or use --output-file", buf.Len(), maxConfigMapSize)
+	}
+
+	var dryRunOpt []string
+	if opts.DryRun {
+		dryRunOpt = []string{metav1.DryRunAll}
+	}
+
+	key := configMapKey(opts)
+	client := clientset.CoreV1().ConfigMaps(opts.configMapNamespace)
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: opts.configMapName, Namespace: opts.configMapNamespace},
+		Data:       map[string]string{key: buf.String()},
+	}
+
+	_, err = client.Create(ctx, configMap, metav1.CreateOptions{DryRun: dryRunOpt})
+	if apierrors.IsAlreadyExists(err) {
+		err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			current, err := client.Get(ctx, opts.configMapName, metav1.GetOptions{})
+			if err != nil {
+				return err
+			}
+
+			if current.Data == nil {
+				current.Data = make(map[string]string)
+			}
+			current.Data[key] = buf.String()
+			_, err = client.Update(ctx, current, metav1.UpdateOptions{DryRun: dryRunOpt})
+			return err
+		})
+	}
+	if err != nil {
+		return 0, interruptedOr(ctx, fmt.Errorf("failed to write ConfigMap %s/%s: %w", opts.configMapNamespace, opts.configMapName, err))
+	}
+
+	opts.logInfo(fmt.Sprintf("Wrote %d migrated RoleBindings to key %s of ConfigMap %s/%s", written, key, opts.configMapNamespace, opts.configMapName),
+		"count", written, "namespace", opts.configMapNamespace, "configmap", opts.configMapName, "key", key, "dry_run", opts.DryRun)
+
+	return written, nil
+}
@@ -0,0 +1,72 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// RoleBindingDiff is the unified diff between the YAML of a source RoleBinding and of its migrated RoleBinding
+type RoleBindingDiff struct {
+	Namespace string
+	Name      string // name of the source RoleBinding
+	Diff      string
+	Added     int
+	Removed   int
+}
+
+// diffRoleBindings renders before and after as YAML and returns their unified diff
+func diffRoleBindings(before *rbacv1.RoleBinding, after *rbacv1.RoleBinding) (RoleBindingDiff, error) {
+	beforeYAML, err := roleBindingYAML(before)
+	if err != nil {
+		return RoleBindingDiff{}, err
+	}
+	afterYAML, err := roleBindingYAML(after)
+	if err != nil {
+		return RoleBindingDiff{}, err
+	}
+
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(strings.TrimSuffix(beforeYAML, "\n")),
+		B:        difflib.SplitLines(strings.TrimSuffix(afterYAML, "\n")),
+		FromFile: fmt.Sprintf("a/%s/%s", before.Namespace, before.Name),
+		ToFile:   fmt.Sprintf("b/%s/%s", after.Namespace, after.Name),
+		Context:  3,
+	})
+	if err != nil {
+		return RoleBindingDiff{}, err
+	}
+
+	rbDiff := RoleBindingDiff{Namespace: before.Namespace, Name: before.Name, Diff: diff}
+	for _, line := range strings.Split(diff, "\n") {
+		switch {
+		case strings.HasPrefix(line, "+++"), strings.HasPrefix(line, "---"):
+		case strings.HasPrefix(line, "+"):
+			rbDiff.Added++
+		case strings.HasPrefix(line, "-"):
+			rbDiff.Removed++
+		}
+	}
+
+	return rbDiff, nil
+}
+
+// roleBindingYAML renders rb as the YAML written to --output-file
+func roleBindingYAML(rb *rbacv1.RoleBinding) (string, error) {
+	obj, err := roleBindingObject(rb)
+	if err != nil {
+		return "", err
+	}
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
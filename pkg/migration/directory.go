@@ -0,0 +1,134 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+	"io"
+)
+
+// DirectoryLookup resolves the email of a UserAccount to its sso user name for the 'user' target
+type DirectoryLookup interface {
+	// LookupUser returns the user name of email, empty when the directory has none
+	LookupUser(email string, ctx context.Context) (string, error)
+}
+
+// BatchDirectoryLookup is a DirectoryLookup able to resolve many emails per request
+type BatchDirectoryLookup interface {
+	DirectoryLookup
+	// LookupUsers returns the user name of each email, empty when the directory has none
+	LookupUsers(emails []string, ctx context.Context) (map[string]string, error)
+}
+
+// directoryLookup returns the directory of the run: Directory when set, else the --identity-url
+// endpoint or the LDAP server, connected on first use
+func (o *MigrateOptions) directoryLookup() (DirectoryLookup, error) {
+	if o.Directory != nil {
+		return o.Directory, nil
+	}
+
+	if o.directory == nil {
+		if o.Identity.URL != "" {
+			o.directory = newHTTPLookup(&o.Identity, o.logger())
+			return o.directory, nil
+		}
+
+		audit, err := openAuditLog(o.LDAP.AuditFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open --ldap-audit-file: %w", err)
+		}
+
+		conn, err := DialLDAP(&o.LDAP)
+		if err != nil {
+			audit.Close()
+			return nil, fmt.Errorf("failed to connect to LDAP server: %w", err)
+		}
+
+		o.directory = &LDAPClient{conn: conn, limiter: o.LDAP.limiter(), logger: o.logger(), multipleMatch: o.LDAP.MultipleMatch, audit: audit, batchSize: o.LDAP.BatchSize}
+	}
+	return o.directory, nil
+}
+
+// closeDirectory closes the directory connection of the run if one was opened, a Directory set
+// by the caller is left open
+func (o *MigrateOptions) closeDirectory() {
+	if closer, ok := o.directory.(io.Closer); ok {
+		closer.Close()
+	}
+	o.directory = nil
+}
+
+// getUser resolves email to the sso user name through the directory of the run
+func (o *MigrateOptions) getUser(email string, ctx context.Context) (string, error) {
+	cEmail := o.cleanEmail(email)
+
+	//Accounts sharing an email, resolvable or not, only cost one directory lookup
+	if userName, ok := o.ldapCache[cEmail]; ok {
+		if userName == "" {
+			o.ldapNegativeHits++
+		} else {
+			o.ldapCacheHits++
+		}
+		return userName, nil
+	}
+
+	userName, prefetched := o.prefetched[cEmail]
+	if !prefetched {
+		dir, err := o.directoryLookup()
+		if err != nil {
+			return "", err
+		}
+
+		userName, err = dir.LookupUser(cEmail, ctx)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	if userName == "" {
+		o.logWarn(fmt.Sprintf("No user found for email %s", cEmail), "email", cEmail)
+	}
+
+	if o.ldapCache == nil {
+		o.ldapCache = make(map[string]string)
+	}
+	o.ldapCache[cEmail] = userName
+
+	return userName, nil
+}
+
+// forgetUsers drops the users cached, prefetched and checkpointed so far, the next lookups query the directory again
+func (o *MigrateOptions) forgetUsers() {
+	o.ldapCache = nil
+	o.prefetched = nil
+	o.checkpoint.forgetAccounts()
+}
+
+// prefetchUsers resolves the cleaned emails in batches when --ldap-batch-size is set and the directory
+// supports it, getUser then serves them without a lookup of their own
+func (o *MigrateOptions) prefetchUsers(emails []string, ctx context.Context) error {
+	if o.LDAP.BatchSize <= 0 || len(emails) == 0 {
+		return nil
+	}
+
+	dir, err := o.directoryLookup()
+	if err != nil {
+		return err
+	}
+	batchDir, ok := dir.(BatchDirectoryLookup)
+	if !ok {
+		return nil
+	}
+
+	users, err := batchDir.LookupUsers(emails, ctx)
+	if err != nil {
+		return err
+	}
+	o.logDebug(fmt.Sprintf("Prefetched %d emails in batches of %d", len(emails), o.LDAP.BatchSize), "emails", len(emails), "batch_size", o.LDAP.BatchSize)
+	o.prefetched = users
+
+	return nil
+}
@@ -0,0 +1,110 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var groupGVR = schema.GroupVersionResource{
+	Group:    "user.openshift.io",
+	Version:  "v1",
+	Resource: "groups",
+}
+
+// getGroupMembers fetches the users of every OpenShift Group referenced as a subject in rbList
+func getGroupMembers(dynclient dynamic.Interface, rbList []rbacv1.RoleBinding, opts *MigrateOptions, ctx context.Context) (map[string][]string, error) {
+	members := make(map[string][]string)
+
+	for _, rb := range rbList {
+		if len(rb.Subjects) == 0 || rb.Subjects[0].Kind != rbacv1.GroupKind {
+			continue
+		}
+
+		group := rb.Subjects[0].Name
+		if _, exists := members[group]; exists {
+			continue
+		}
+
+		obj, err := dynclient.Resource(groupGVR).Get(ctx, group, metav1.GetOptions{})
+		if err != nil {
+			if err := interrupted(ctx); err != nil {
+				return nil, err
+			}
+			opts.logWarn(fmt.Sprintf("Failed to get Group %s, it will not be expanded: %v", group, err), "group", group, "error", err)
+			members[group] = nil
+			continue
+		}
+
+		users, _, err := unstructured.NestedStringSlice(obj.Object, "users")
+		if err != nil {
+			opts.logWarn(fmt.Sprintf("Group %s has an unexpected users field, it will not be expanded: %v", group, err), "group", group, "error", err)
+			members[group] = nil
+			continue
+		}
+		//The API server drops the users field of a Group without users
+		if users == nil {
+			users = []string{}
+		}
+
+		opts.logInfo(fmt.Sprintf("Group %s expanded to %d users", group, len(users)), "group", group, "count", len(users))
+		members[group] = users
+	}
+
+	return members, nil
+}
+
+// expandGroupSubjects replaces every RoleBinding to a Group with one RoleBinding per member user,
+// named after the original with the group name swapped for the user name (or the user name appended),
+// so they are mapped through idMap as any other user binding. Groups that could not be fetched are kept,
+// Groups without users are kept marked for the migration to skip
+func expandGroupSubjects(rbList []rbacv1.RoleBinding, members map[string][]string) []rbacv1.RoleBinding {
+	expanded := make([]rbacv1.RoleBinding, 0, len(rbList))
+
+	for _, rb := range rbList {
+		if len(rb.Subjects) == 0 || rb.Subjects[0].Kind != rbacv1.GroupKind || members[rb.Subjects[0].Name] == nil {
+			expanded = append(expanded, rb)
+			continue
+		}
+
+		group := rb.Subjects[0].Name
+		if len(members[group]) == 0 {
+			erb := rb.DeepCopy()
+			if erb.Annotations == nil {
+				erb.Annotations = make(map[string]string)
+			}
+			erb.Annotations[emptyGroupAnnotation] = "true"
+			expanded = append(expanded, *erb)
+			continue
+		}
+		for _, user := range members[group] {
+			urb := deriveRoleBinding(&rb)
+			if strings.Contains(rb.Name, group) {
+				urb.Name = strings.Replace(rb.Name, group, user, 1)
+			} else {
+				urb.Name = fmt.Sprintf("%s-%s", rb.Name, user)
+			}
+			urb.Subjects = []rbacv1.Subject{{
+				Kind:     rbacv1.UserKind,
+				APIGroup: rbacv1.GroupName,
+				Name:     user,
+			}}
+			expanded = append(expanded, *urb)
+		}
+	}
+
+	return expanded
+}
+
+// emptyGroupAnnotation marks the RoleBindings to a Group without users, which have no user to migrate
+const emptyGroupAnnotation = "rbac-migration.konflux-ci.dev/empty-group"
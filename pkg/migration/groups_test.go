@@ -0,0 +1,63 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// openShiftGroup returns an OpenShift Group, without users field when users is nil as the API server serves it
+func openShiftGroup(name string, users []interface{}) *unstructured.Unstructured {
+	object := map[string]interface{}{
+		"apiVersion": "user.openshift.io/v1",
+		"kind":       "Group",
+		"metadata":   map[string]interface{}{"name": name},
+	}
+	if users != nil {
+		object["users"] = users
+	}
+	return &unstructured.Unstructured{Object: object}
+}
+
+func TestExpandGroupSubjectsSkipsEmptyGroups(t *testing.T) {
+	opts := testOptions(t, func(o *MigrateOptions) { o.ExpandGroups = true })
+	dynclient := dynamicfake.NewSimpleDynamicClient(runtime.NewScheme(),
+		openShiftGroup("team", []interface{}{"alice"}),
+		openShiftGroup("emptied", []interface{}{}),
+		openShiftGroup("never-filled", nil),
+	)
+	rbList := []rbacv1.RoleBinding{
+		tenantRoleBinding("tenant", "appstudio-team", "appstudio-user-actions", groupSubject("team")),
+		tenantRoleBinding("tenant", "appstudio-emptied", "appstudio-user-actions", groupSubject("emptied")),
+		tenantRoleBinding("tenant", "appstudio-never-filled", "appstudio-user-actions", groupSubject("never-filled")),
+	}
+
+	members, err := getGroupMembers(dynclient, rbList, opts, context.Background())
+	if err != nil {
+		t.Fatalf("getGroupMembers() failed: %v", err)
+	}
+	expanded := expandGroupSubjects(rbList, members)
+	if len(expanded) != 3 {
+		t.Fatalf("expanded into %d RoleBindings, want the member of team and both empty Groups kept", len(expanded))
+	}
+
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith"}, expanded, opts)
+	if len(result.RoleBindings) != 1 || result.RoleBindings[0].Subjects[0].Name != "asmith" {
+		t.Errorf("migrated %v, want only the RoleBinding of alice", result.RoleBindings)
+	}
+	summary := result.Namespaces[0]
+	if got := summary.Skipped[SkipEmptyGroup]; got != 2 {
+		t.Errorf("skipped %d RoleBindings as %s, want the 2 of the empty Groups", got, SkipEmptyGroup)
+	}
+	if summary.Source != 3 {
+		t.Errorf("summary counts %d source RoleBindings, want 3", summary.Source)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// testOptions returns the default options, changed by mutate when it is not nil, compiled and logging nowhere
+func testOptions(t *testing.T, mutate func(o *MigrateOptions)) *MigrateOptions {
+	t.Helper()
+
+	opts := DefaultMigrateOptions()
+	opts.OutputFile = ""
+	opts.Logger = slog.New(slog.NewTextHandler(io.Discard, nil))
+	if mutate != nil {
+		mutate(&opts)
+	}
+	if err := opts.compile(); err != nil {
+		t.Fatalf("compile() failed: %v", err)
+	}
+
+	return &opts
+}
+
+// tenantRoleBinding returns a kubesaw Tenant RoleBinding to the ClusterRole role
+func tenantRoleBinding(namespace string, name string, role string, subjects ...rbacv1.Subject) rbacv1.RoleBinding {
+	return rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: namespace,
+			Labels:    map[string]string{"toolchain.dev.openshift.com/provider": "codeready-toolchain"},
+		},
+		RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role},
+		Subjects: subjects,
+	}
+}
+
+// userSubject returns a User subject as kubesaw binds it
+func userSubject(name string) rbacv1.Subject {
+	return rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: name}
+}
+
+// groupSubject returns a Group subject
+func groupSubject(name string) rbacv1.Subject {
+	return rbacv1.Subject{Kind: rbacv1.GroupKind, APIGroup: rbacv1.GroupName, Name: name}
+}
+
+// userAccount returns a UserAccount whose email claim is email
+func userAccount(name string, email interface{}) unstructured.Unstructured {
+	return unstructured.Unstructured{Object: map[string]interface{}{
+		"metadata": map[string]interface{}{"name": name, "namespace": "toolchain-member-operator"},
+		"spec": map[string]interface{}{
+			"propagatedClaims": map[string]interface{}{"email": email},
+		},
+	}}
+}
+
+// migrateRoleBindings runs the mutation stage over rbList, failing the test on error
+func migrateRoleBindings(t *testing.T, idMap map[string]string, rbList []rbacv1.RoleBinding, opts *MigrateOptions) *Result {
+	t.Helper()
+
+	result := &Result{}
+	if err := mutateTenantRoleBindings(idMap, nil, rbList, opts, result); err != nil {
+		t.Fatalf("mutateTenantRoleBindings() failed: %v", err)
+	}
+
+	return result
+}
+
+// ptr returns a pointer to a copy of v
+func ptr[T any](v T) *T {
+	return &v
+}
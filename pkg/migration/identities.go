@@ -0,0 +1,52 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+)
+
+var userGVR = schema.GroupVersionResource{
+	Group:    "user.openshift.io",
+	Version:  "v1",
+	Resource: "users",
+}
+
+// validateIdentities checks every distinct User subject of the migrated RoleBindings exists as an OpenShift
+// User. A missing one is warned about, the id is usually right but the user has not logged in to the cluster yet
+func validateIdentities(dynclient dynamic.Interface, rbList []rbacv1.RoleBinding, opts *MigrateOptions, ctx context.Context) error {
+	checked := make(map[string]bool)
+	missing := 0
+
+	for _, rb := range rbList {
+		for _, subject := range rb.Subjects {
+			if subject.Kind != rbacv1.UserKind || checked[subject.Name] {
+				continue
+			}
+			checked[subject.Name] = true
+
+			_, err := dynclient.Resource(userGVR).Get(ctx, subject.Name, metav1.GetOptions{})
+			if apierrors.IsNotFound(err) {
+				opts.logWarn(fmt.Sprintf("User %s bound by RoleBinding %s in Namespace %s does not exist on the cluster, the binding has no effect until it logs in", subject.Name, rb.Name, rb.Namespace), "namespace", rb.Namespace, "name", rb.Name, "user", subject.Name)
+				missing++
+				continue
+			}
+			if err != nil {
+				return interruptedOr(ctx, fmt.Errorf("failed to get User %s: %w", subject.Name, err))
+			}
+		}
+	}
+
+	opts.logInfo(fmt.Sprintf("Validated %d target users, %d missing", len(checked), missing), "count", len(checked), "missing", missing)
+
+	return nil
+}
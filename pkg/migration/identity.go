@@ -0,0 +1,183 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// DefaultIDJSONField is the field of the identity response holding the user name, as in OIDC userinfo
+const DefaultIDJSONField = "preferred_username"
+
+// IdentityOptions holds the settings of an HTTP identity endpoint, e.g. OIDC userinfo or SCIM,
+// resolving emails instead of LDAP
+type IdentityOptions struct {
+	// URL is queried with GET, the email replaces an {email} placeholder or is sent as the email query parameter
+	URL string
+	// Token is sent as a bearer token when set
+	Token string
+	// IDField is the dotted path of the user name in the JSON response, e.g. Resources.0.userName
+	IDField string
+	// ProxyURL is the HTTP(S) proxy of the requests, the HTTPS_PROXY and NO_PROXY environment when empty
+	ProxyURL string
+}
+
+// validate checks the URL and the id field of the identity endpoint
+func (o *IdentityOptions) validate() error {
+	u, err := url.Parse(strings.ReplaceAll(o.URL, "{email}", "email"))
+	if err != nil {
+		return err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("unsupported scheme %q, select an http:// or https:// URL", u.Scheme)
+	}
+	if o.IDField == "" {
+		return fmt.Errorf("--id-json-field must not be empty")
+	}
+	if o.ProxyURL != "" {
+		if _, err := parseProxyURL(o.ProxyURL); err != nil {
+			return fmt.Errorf("--identity-proxy-url: %w", err)
+		}
+	}
+	return nil
+}
+
+// CheckIdentity validates the identity endpoint and looks up an email no user has, so an unreachable endpoint
+// or a rejected token fails while a not found user passes
+func CheckIdentity(o *IdentityOptions, ctx context.Context) error {
+	if err := o.validate(); err != nil {
+		return err
+	}
+
+	_, err := newHTTPLookup(o, slog.New(slog.NewTextHandler(io.Discard, nil))).LookupUser("wscli-check@example.invalid", ctx)
+	return err
+}
+
+// parseProxyURL parses an http:// or https:// proxy URL
+func parseProxyURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("unsupported scheme %q, select an http:// or https:// proxy URL", u.Scheme)
+	}
+	return u, nil
+}
+
+// HTTPLookup is the DirectoryLookup of an HTTP identity endpoint
+type HTTPLookup struct {
+	options *IdentityOptions
+	client  *http.Client
+	logger  *slog.Logger
+}
+
+func newHTTPLookup(o *IdentityOptions, logger *slog.Logger) *HTTPLookup {
+	//The default transport already honors the proxy environment
+	client := http.DefaultClient
+	if proxyURL, err := parseProxyURL(o.ProxyURL); err == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.Proxy = http.ProxyURL(proxyURL)
+		client = &http.Client{Transport: transport}
+	}
+
+	return &HTTPLookup{options: o, client: client, logger: logger}
+}
+
+// requestURL returns the identity URL querying email
+func (hl *HTTPLookup) requestURL(email string) (*url.URL, error) {
+	if strings.Contains(hl.options.URL, "{email}") {
+		return url.Parse(strings.ReplaceAll(hl.options.URL, "{email}", url.QueryEscape(email)))
+	}
+
+	u, err := url.Parse(hl.options.URL)
+	if err != nil {
+		return nil, err
+	}
+	query := u.Query()
+	query.Set("email", email)
+	u.RawQuery = query.Encode()
+	return u, nil
+}
+
+// LookupUser resolves email to the user name at IDField of the endpoint response, a 404 or a
+// response without the field is not found
+func (hl *HTTPLookup) LookupUser(email string, ctx context.Context) (string, error) {
+	u, err := hl.requestURL(email)
+	if err != nil {
+		return "", fmt.Errorf("failed to build identity request for email %s: %w", email, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Accept", "application/json")
+	if hl.options.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+hl.options.Token)
+	}
+
+	resp, err := hl.client.Do(req)
+	if err != nil {
+		return "", interruptedOr(ctx, fmt.Errorf("error found looking up email %s: %w", email, err))
+	}
+	defer resp.Body.Close()
+
+	//The token is a header, so the logged URL never holds it
+	hl.logger.Debug(fmt.Sprintf("Identity lookup %s returned %s", u.Redacted(), resp.Status), "url", u.Redacted(), "status", resp.StatusCode)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return "", nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", fmt.Errorf("error found looking up email %s: %s", email, resp.Status)
+	}
+
+	var body any
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", fmt.Errorf("failed to decode identity response for email %s: %w", email, err)
+	}
+
+	value, found := jsonField(body, strings.Split(hl.options.IDField, "."))
+	if !found || value == nil {
+		return "", nil
+	}
+	id, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("identity response field %s for email %s is a %T, not a string", hl.options.IDField, email, value)
+	}
+
+	return strings.TrimSpace(id), nil
+}
+
+// jsonField walks path through the decoded JSON value, numeric elements index arrays
+func jsonField(value any, path []string) (any, bool) {
+	for _, key := range path {
+		switch v := value.(type) {
+		case map[string]any:
+			var ok bool
+			if value, ok = v[key]; !ok {
+				return nil, false
+			}
+		case []any:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(v) {
+				return nil, false
+			}
+			value = v[i]
+		default:
+			return nil, false
+		}
+	}
+	return value, true
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
+	"sigs.k8s.io/yaml"
+)
+
+// loadRoleBindingsFile reads the RoleBindings of the --input-file, either --- separated YAML documents
+// or JSON objects, each a RoleBinding or a List of them as written by kubectl get -o yaml
+func loadRoleBindingsFile(path string) ([]rbacv1.RoleBinding, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var rbList []rbacv1.RoleBinding
+	decoder := utilyaml.NewYAMLOrJSONDecoder(file, 4096)
+	for {
+		obj := &unstructured.Unstructured{}
+		if err := decoder.Decode(&obj.Object); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+		if len(obj.Object) == 0 {
+			continue
+		}
+
+		items := []unstructured.Unstructured{*obj}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, err
+			}
+			items = list.Items
+		}
+
+		for _, item := range items {
+			if item.GetKind() != "RoleBinding" {
+				return nil, fmt.Errorf("unexpected %s %s, only RoleBindings are supported", item.GetKind(), item.GetName())
+			}
+
+			var rb rbacv1.RoleBinding
+			if err := runtime.DefaultUnstructuredConverter.FromUnstructured(item.Object, &rb); err != nil {
+				return nil, fmt.Errorf("invalid RoleBinding %s: %w", item.GetName(), err)
+			}
+			rbList = append(rbList, rb)
+		}
+	}
+
+	return rbList, nil
+}
+
+// loadIDMapFile reads the --id-map-file, a YAML or JSON object mapping kubesaw account names to sso ids
+func loadIDMapFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	idMap := make(map[string]string)
+	if err := yaml.UnmarshalStrict(data, &idMap); err != nil {
+		return nil, err
+	}
+
+	return idMap, nil
+}
@@ -0,0 +1,85 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	ldap "github.com/go-ldap/ldap/v3"
+	"github.com/go-ldap/ldap/v3/gssapi"
+)
+
+// DefaultKrb5Conf is the Kerberos configuration read by the gssapi --ldap-auth
+const DefaultKrb5Conf = "/etc/krb5.conf"
+
+// defaultCCache returns the credential cache of kinit: $KRB5CCNAME when set, /tmp/krb5cc_<uid> otherwise
+func defaultCCache() string {
+	if ccache := os.Getenv("KRB5CCNAME"); ccache != "" {
+		return strings.TrimPrefix(ccache, "FILE:")
+	}
+
+	return fmt.Sprintf("/tmp/krb5cc_%d", os.Getuid())
+}
+
+// kerberosClient loads the Kerberos credentials of the gssapi bind, from --ldap-keytab for --ldap-principal
+// when set or from the credential cache left by kinit
+func (o *LDAPOptions) kerberosClient() (*gssapi.Client, error) {
+	krb5Conf := o.Krb5Conf
+	if krb5Conf == "" {
+		krb5Conf = DefaultKrb5Conf
+	}
+
+	if o.Keytab != "" {
+		if o.Principal == "" {
+			return nil, fmt.Errorf("--ldap-keytab requires --ldap-principal")
+		}
+		username, realm := splitPrincipal(o.Principal)
+		client, err := gssapi.NewClientWithKeytab(username, realm, o.Keytab, krb5Conf)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load Kerberos keytab %s with %s: %w", o.Keytab, krb5Conf, err)
+		}
+		return client, nil
+	}
+
+	ccache := defaultCCache()
+	client, err := gssapi.NewClientFromCCache(ccache, krb5Conf)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load Kerberos credential cache %s with %s, run kinit or pass --ldap-keytab: %w", ccache, krb5Conf, err)
+	}
+
+	return client, nil
+}
+
+// splitPrincipal splits user@REALM, the realm is empty for the default realm of the krb5.conf
+func splitPrincipal(principal string) (string, string) {
+	if i := strings.LastIndex(principal, "@"); i >= 0 {
+		return principal[:i], principal[i+1:]
+	}
+
+	return principal, ""
+}
+
+// bindGSSAPI performs a GSSAPI SASL bind on conn as the Kerberos identity, for the ldap/<host> service principal
+func (o *LDAPOptions) bindGSSAPI(conn *ldap.Conn) error {
+	client, err := o.kerberosClient()
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	host, _, err := net.SplitHostPort(o.Host)
+	if err != nil {
+		host = o.Host
+	}
+
+	if err := conn.GSSAPIBind(client, "ldap/"+host, ""); err != nil {
+		return fmt.Errorf("GSSAPI bind to %s failed: %w", o.Host, err)
+	}
+
+	return nil
+}
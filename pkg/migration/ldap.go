@@ -0,0 +1,431 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+
+	ldap "github.com/go-ldap/ldap/v3"
+	"golang.org/x/net/proxy"
+	"golang.org/x/time/rate"
+)
+
+// DefaultLDAPHost is the host:port of the corporate LDAP server
+const DefaultLDAPHost = "ldap.corp.redhat.com:389"
+
+// LDAPOptions holds the settings of the LDAP connection
+type LDAPOptions struct {
+	Host       string
+	TLS        string // 'none', 'ldaps' or 'starttls'
+	ClientCert string
+	ClientKey  string
+	QPS        float64 // maximum searches per second, 0 for no limit
+	// MultipleMatch selects what an email matching several entries resolves to: 'error', 'first' or 'skip'
+	MultipleMatch string
+	// AuditFile is appended one JSON line per search, empty to not audit
+	AuditFile string
+	// BatchSize is the number of emails searched by mail in a single OR filter, 0 to search them one by one
+	BatchSize int
+	Auth      string // 'none', 'simple' or 'gssapi'
+	Keytab    string // gssapi credentials of Principal, the kinit credential cache when empty
+	Principal string // user@REALM of Keytab
+	Krb5Conf  string // Kerberos configuration, DefaultKrb5Conf when empty
+	ProxyURL  string // socks5:// proxy the connection goes through, empty to connect directly
+	BindDN    string // simple bind identity
+	// BindPassword of BindDN, overridden by BindPasswordFile, BindPasswordStdin and then $WSCLI_LDAP_BIND_PASSWORD
+	BindPassword      string
+	BindPasswordFile  string
+	BindPasswordStdin bool
+
+	// bindPassword caches the resolved password, stdin can only be read once
+	bindPassword *string
+}
+
+// BindPasswordEnv is the environment variable holding the simple bind password
+const BindPasswordEnv = "WSCLI_LDAP_BIND_PASSWORD"
+
+// errMultipleMatches is returned by the searches matching several entries unless --ldap-multiple-match is 'first'
+var errMultipleMatches = errors.New("ambiguous LDAP match")
+
+// LDAPClient is the DirectoryLookup of the corporate LDAP server, holding the connection of a run
+type LDAPClient struct {
+	conn          *ldap.Conn
+	limiter       *rate.Limiter
+	logger        *slog.Logger
+	multipleMatch string
+	audit         *auditLog
+	batchSize     int
+}
+
+// tlsConfig returns the TLS settings for the LDAP connection, loading the client certificate if one is set
+func (o *LDAPOptions) tlsConfig() (*tls.Config, error) {
+	serverName, _, err := net.SplitHostPort(o.Host)
+	if err != nil {
+		serverName = o.Host
+	}
+
+	config := &tls.Config{ServerName: serverName}
+
+	if o.ClientCert != "" || o.ClientKey != "" {
+		cert, err := tls.LoadX509KeyPair(o.ClientCert, o.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load LDAP client certificate %s and key %s: %w", o.ClientCert, o.ClientKey, err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
+
+// limiter returns the token bucket capping the LDAP search rate to QPS
+func (o *LDAPOptions) limiter() *rate.Limiter {
+	if o.QPS <= 0 {
+		return rate.NewLimiter(rate.Inf, 0)
+	}
+	return rate.NewLimiter(rate.Limit(o.QPS), 1)
+}
+
+// DialLDAP opens a new connection to the LDAP server, over TLS when selected, and binds with Kerberos
+// for the gssapi Auth. With a client certificate the server authorizes the connection from the certificate
+// so no bind is needed
+func DialLDAP(o *LDAPOptions) (*ldap.Conn, error) {
+	conn, err := dialLDAP(o)
+	if err != nil {
+		return nil, err
+	}
+
+	switch o.Auth {
+	case "none", "":
+	case "simple":
+		if err := o.bindSimple(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	case "gssapi":
+		if err := o.bindGSSAPI(conn); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	default:
+		conn.Close()
+		return nil, fmt.Errorf("invalid --ldap-auth %q, select between 'none', 'simple' and 'gssapi'", o.Auth)
+	}
+
+	return conn, nil
+}
+
+// bindSimple binds conn as BindDN with the resolved password
+func (o *LDAPOptions) bindSimple(conn *ldap.Conn) error {
+	if o.BindDN == "" {
+		return fmt.Errorf("--ldap-auth simple requires --ldap-bind-dn")
+	}
+	password, err := o.resolveBindPassword()
+	if err != nil {
+		return err
+	}
+
+	if err := conn.Bind(o.BindDN, password); err != nil {
+		return fmt.Errorf("simple bind to %s as %s failed: %w", o.Host, o.BindDN, err)
+	}
+
+	return nil
+}
+
+// resolveBindPassword returns the bind password from BindPasswordFile, stdin, $WSCLI_LDAP_BIND_PASSWORD or
+// BindPassword in that order, reading it once. A single trailing newline is trimmed from the file and stdin
+func (o *LDAPOptions) resolveBindPassword() (string, error) {
+	if o.bindPassword != nil {
+		return *o.bindPassword, nil
+	}
+
+	password := o.BindPassword
+	switch {
+	case o.BindPasswordFile != "":
+		content, err := os.ReadFile(o.BindPasswordFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read --ldap-bind-password-file: %w", err)
+		}
+		password = trimNewline(string(content))
+	case o.BindPasswordStdin:
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return "", fmt.Errorf("failed to read the LDAP bind password from stdin: %w", err)
+		}
+		password = trimNewline(line)
+	case os.Getenv(BindPasswordEnv) != "":
+		password = os.Getenv(BindPasswordEnv)
+	}
+	if password == "" {
+		return "", fmt.Errorf("--ldap-auth simple requires a bind password, set --ldap-bind-password-file, --ldap-bind-password-stdin or $%s", BindPasswordEnv)
+	}
+
+	o.bindPassword = &password
+	return password, nil
+}
+
+// trimNewline trims a single trailing \n or \r\n
+func trimNewline(s string) string {
+	s = strings.TrimSuffix(s, "\n")
+	return strings.TrimSuffix(s, "\r")
+}
+
+// dialLDAP opens the connection of DialLDAP, over TLS when selected
+func dialLDAP(o *LDAPOptions) (*ldap.Conn, error) {
+	if (o.ClientCert == "") != (o.ClientKey == "") {
+		return nil, fmt.Errorf("--ldap-client-cert and --ldap-client-key go together")
+	}
+
+	switch o.TLS {
+	case "none", "":
+		if o.ClientCert != "" {
+			return nil, fmt.Errorf("--ldap-client-cert and --ldap-client-key require --ldap-tls ldaps or starttls")
+		}
+		return o.dial(nil)
+	case "ldaps":
+		tlsConfig, err := o.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := o.dial(tlsConfig)
+		if err != nil {
+			return nil, fmt.Errorf("TLS connection to %s failed, the server may have rejected the client certificate: %w", o.Host, err)
+		}
+		return conn, nil
+	case "starttls":
+		tlsConfig, err := o.tlsConfig()
+		if err != nil {
+			return nil, err
+		}
+		conn, err := o.dial(nil)
+		if err != nil {
+			return nil, err
+		}
+		if err := conn.StartTLS(tlsConfig); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS with %s failed, the server may have rejected the client certificate: %w", o.Host, err)
+		}
+		return conn, nil
+	default:
+		return nil, fmt.Errorf("invalid --ldap-tls %q, select between 'none', 'ldaps' and 'starttls'", o.TLS)
+	}
+}
+
+// dial connects to Host, through the SOCKS ProxyURL when set, and over TLS with tlsConfig when it is not nil
+func (o *LDAPOptions) dial(tlsConfig *tls.Config) (*ldap.Conn, error) {
+	if o.ProxyURL == "" {
+		if tlsConfig != nil {
+			return ldap.DialURL("ldaps://"+o.Host, ldap.DialWithTLSConfig(tlsConfig))
+		}
+		return ldap.DialURL("ldap://" + o.Host)
+	}
+
+	proxyURL, err := parseSOCKSURL(o.ProxyURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --ldap-proxy-url: %w", err)
+	}
+	dialer, err := proxy.FromURL(proxyURL, proxy.Direct)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --ldap-proxy-url: %w", err)
+	}
+	c, err := dialer.Dial("tcp", o.Host)
+	if err != nil {
+		return nil, fmt.Errorf("connection to %s through proxy %s failed: %w", o.Host, proxyURL.Redacted(), err)
+	}
+	if tlsConfig != nil {
+		tlsConn := tls.Client(c, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			c.Close()
+			return nil, err
+		}
+		c = tlsConn
+	}
+
+	conn := ldap.NewConn(c, tlsConfig != nil)
+	conn.Start()
+	return conn, nil
+}
+
+// parseSOCKSURL parses the socks5:// or socks5h:// URL of --ldap-proxy-url
+func parseSOCKSURL(raw string) (*url.URL, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != "socks5" && u.Scheme != "socks5h" {
+		return nil, fmt.Errorf("unsupported scheme %q, select a socks5:// URL", u.Scheme)
+	}
+	return u, nil
+}
+
+// Close closes the LDAP connection and the audit file
+func (lc *LDAPClient) Close() error {
+	return errors.Join(lc.conn.Close(), lc.audit.Close())
+}
+
+// search returns the uid of the first entry whose emailField is email, empty when there is none, and
+// records the search in the --ldap-audit-file
+func (lc *LDAPClient) search(email string, emailField string, ctx context.Context) (string, error) {
+	uids, err := lc.searchUIDs(email, emailField, ctx)
+
+	return lc.pickUID(email, emailField, uids, err)
+}
+
+// pickUID returns the first of the uids found for email, applying --ldap-multiple-match, and records
+// the search in the --ldap-audit-file
+func (lc *LDAPClient) pickUID(email string, emailField string, uids []string, err error) (string, error) {
+	uid := ""
+	if err == nil && len(uids) > 0 {
+		uid = uids[0]
+		//Picking one of several people would grant the permissions to the wrong sso identity
+		if len(uids) > 1 {
+			lc.logger.Warn(fmt.Sprintf("Email %s matches %d LDAP entries by %s: %s", email, len(uids), emailField, strings.Join(uids, ", ")), "email", email, "field", emailField, "uids", uids)
+			if lc.multipleMatch != "first" {
+				uid = ""
+				err = fmt.Errorf("%w: email %s matches %d LDAP entries: %s", errMultipleMatches, email, len(uids), strings.Join(uids, ", "))
+			}
+		}
+	}
+
+	if auditErr := lc.audit.record(email, emailField, uid, len(uids), err); auditErr != nil && err == nil {
+		err = fmt.Errorf("failed to write --ldap-audit-file: %w", auditErr)
+	}
+
+	return uid, err
+}
+
+// searchUIDs returns the uids of the entries whose emailField is email
+func (lc *LDAPClient) searchUIDs(email string, emailField string, ctx context.Context) ([]string, error) {
+	//Escaping the email so special characters can't alter the filter
+	searchFilter := fmt.Sprintf("(%s=%s)", emailField, ldap.EscapeFilter(email))
+
+	entries, err := lc.searchEntries(searchFilter, []string{"uid"}, "email "+email, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	uids := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		uids = append(uids, entryUID(entry))
+	}
+
+	return uids, nil
+}
+
+// entryUID returns the uid of entry, trimmed of the stray whitespace some directory entries carry
+func entryUID(entry *ldap.Entry) string {
+	return strings.TrimSpace(entry.GetAttributeValue("uid"))
+}
+
+// searchEntries returns the user entries matching searchFilter with their attributes, what describes
+// the search in the errors
+func (lc *LDAPClient) searchEntries(searchFilter string, attributes []string, what string, ctx context.Context) ([]*ldap.Entry, error) {
+	searchBase := "ou=users,dc=redhat,dc=com"
+
+	searchRequest := ldap.NewSearchRequest(
+		searchBase,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0, 0, false,
+		searchFilter,
+		attributes,
+		nil,
+	)
+
+	//Stay under the directory quota, shared by every lookup on the connection
+	if err := lc.limiter.Wait(ctx); err != nil {
+		return nil, interruptedOr(ctx, err)
+	}
+
+	//Async search so an interrupted run abandons the in-flight request
+	sr := lc.conn.SearchAsync(ctx, searchRequest, 0)
+
+	var entries []*ldap.Entry
+	for sr.Next() {
+		entries = append(entries, sr.Entry())
+	}
+
+	if err := sr.Err(); err != nil {
+		return nil, interruptedOr(ctx, fmt.Errorf("error found searching for %s: %w", what, err))
+	}
+
+	//Only the query and the entry count are logged, never the returned attributes or credentials
+	lc.logger.Debug(fmt.Sprintf("LDAP search base %s filter %s returned %d entries", searchBase, searchFilter, len(entries)), "base", searchBase, "filter", searchFilter, "entries", len(entries))
+
+	return entries, nil
+}
+
+// LookupUser resolves email to the sso user name, searching by mail then by alias
+// An ambiguous email is left unresolved with --ldap-multiple-match skip, without trying the alias
+func (lc *LDAPClient) LookupUser(email string, ctx context.Context) (string, error) {
+	userName, err := lc.search(email, "mail", ctx)
+	if err == nil && userName == "" {
+		userName, err = lc.search(email, "rhatPreferredAlias", ctx)
+	}
+	if errors.Is(err, errMultipleMatches) && lc.multipleMatch == "skip" {
+		return "", nil
+	}
+	return userName, err
+}
+
+// LookupUsers resolves the emails like LookupUser, searching them by mail with one OR filter per --ldap-batch-size
+// emails and mapping the uids back by the returned mail. The emails without a mail entry are searched by alias one by one
+func (lc *LDAPClient) LookupUsers(emails []string, ctx context.Context) (map[string]string, error) {
+	batchSize := lc.batchSize
+	if batchSize <= 0 {
+		batchSize = 1
+	}
+
+	users := make(map[string]string, len(emails))
+	for batch := range slices.Chunk(emails, batchSize) {
+		var sb strings.Builder
+		sb.WriteString("(|")
+		for _, email := range batch {
+			fmt.Fprintf(&sb, "(mail=%s)", ldap.EscapeFilter(email))
+		}
+		sb.WriteString(")")
+
+		entries, err := lc.searchEntries(sb.String(), []string{"uid", "mail"}, fmt.Sprintf("%d emails", len(batch)), ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		//Directory mails are matched case-insensitively, so the entries are keyed by the lowercased mail
+		uidsByMail := make(map[string][]string)
+		for _, entry := range entries {
+			uid := entryUID(entry)
+			for _, mail := range entry.GetAttributeValues("mail") {
+				key := strings.ToLower(strings.TrimSpace(mail))
+				uidsByMail[key] = append(uidsByMail[key], uid)
+			}
+		}
+
+		for _, email := range batch {
+			userName, err := lc.pickUID(email, "mail", uidsByMail[strings.ToLower(email)], nil)
+			if err == nil && userName == "" {
+				userName, err = lc.search(email, "rhatPreferredAlias", ctx)
+			}
+			if errors.Is(err, errMultipleMatches) && lc.multipleMatch == "skip" {
+				userName, err = "", nil
+			}
+			if err != nil {
+				return nil, err
+			}
+			users[email] = userName
+		}
+	}
+
+	return users, nil
+}
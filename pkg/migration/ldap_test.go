@@ -0,0 +1,151 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	ldap "github.com/go-ldap/ldap/v3"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestEntryUID(t *testing.T) {
+	tests := []struct {
+		name     string
+		uid      []string
+		expected string
+	}{
+		{name: "plain", uid: []string{"asmith"}, expected: "asmith"},
+		{name: "surrounding spaces", uid: []string{"  asmith "}, expected: "asmith"},
+		{name: "tabs and newline", uid: []string{"\tasmith\n"}, expected: "asmith"},
+		{name: "missing", expected: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entry := ldap.NewEntry("uid=asmith,ou=users,dc=redhat,dc=com", map[string][]string{"uid": tt.uid})
+			if got := entryUID(entry); got != tt.expected {
+				t.Errorf("entryUID() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+// directoryStub resolves the emails of its map
+type directoryStub map[string]string
+
+func (d directoryStub) LookupUser(email string, ctx context.Context) (string, error) {
+	return d[email], nil
+}
+
+func TestBuildIDMapLowercaseIDs(t *testing.T) {
+	for _, lowercase := range []bool{false, true} {
+		opts := testOptions(t, func(o *MigrateOptions) {
+			o.LowercaseIDs = lowercase
+			o.Directory = directoryStub{"alice@redhat.com": "ASmith"}
+		})
+		accounts := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{userAccount("alice", "alice@redhat.com")}}
+
+		idMap, unresolved, err := buildIDMap(accounts, opts.transform, opts, context.Background())
+		if err != nil {
+			t.Fatalf("buildIDMap() failed: %v", err)
+		}
+
+		expected := "ASmith"
+		if lowercase {
+			expected = "asmith"
+		}
+		if idMap["alice"] != expected || len(unresolved) != 0 {
+			t.Errorf("with --lowercase-ids=%v got %v, unresolved %v, want alice mapped to %s", lowercase, idMap, unresolved, expected)
+		}
+	}
+}
+
+func TestForgetUsersLooksUpAgain(t *testing.T) {
+	directory := directoryStub{}
+	opts := testOptions(t, func(o *MigrateOptions) { o.Directory = directory })
+
+	if user, err := opts.getUser("alice@redhat.com", context.Background()); err != nil || user != "" {
+		t.Fatalf("getUser() = %q, %v, want no user yet", user, err)
+	}
+	directory["alice@redhat.com"] = "asmith"
+	if user, _ := opts.getUser("alice@redhat.com", context.Background()); user != "" {
+		t.Errorf("getUser() = %q before forgetUsers, want the cached miss", user)
+	}
+
+	opts.forgetUsers()
+
+	if user, _ := opts.getUser("alice@redhat.com", context.Background()); user != "asmith" {
+		t.Errorf("getUser() = %q after forgetUsers, want asmith", user)
+	}
+}
+
+func TestForgetUsersBypassesCheckpoint(t *testing.T) {
+	directory := directoryStub{"alice@redhat.com": "asmith"}
+	opts := testOptions(t, func(o *MigrateOptions) {
+		o.Directory = directory
+		o.Checkpoint = filepath.Join(t.TempDir(), "migrate.checkpoint.json")
+	})
+	accounts := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{userAccount("alice", "alice@redhat.com")}}
+
+	if idMap, _, err := buildIDMap(accounts, opts.transform, opts, context.Background()); err != nil || idMap["alice"] != "asmith" {
+		t.Fatalf("buildIDMap() = %v, %v, want alice mapped to asmith", idMap, err)
+	}
+	//Renamed in the directory during the watch
+	directory["alice@redhat.com"] = "alice.smith"
+
+	opts.forgetUsers()
+
+	idMap, _, err := buildIDMap(accounts, opts.transform, opts, context.Background())
+	if err != nil {
+		t.Fatalf("buildIDMap() failed: %v", err)
+	}
+	if idMap["alice"] != "alice.smith" {
+		t.Errorf("refreshed id of alice = %q, want alice.smith instead of the checkpointed asmith", idMap["alice"])
+	}
+	if id, _ := opts.checkpoint.account(opts.transform.name, "alice"); id != "alice.smith" {
+		t.Errorf("checkpointed id of alice = %q, want the refreshed alice.smith", id)
+	}
+}
+
+func TestDialLDAPClientCertificate(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  LDAPOptions
+		expected string
+	}{
+		{
+			name:     "certificate without key",
+			options:  LDAPOptions{TLS: "ldaps", ClientCert: "client.crt"},
+			expected: "go together",
+		},
+		{
+			name:     "key without certificate",
+			options:  LDAPOptions{TLS: "ldaps", ClientKey: "client.key"},
+			expected: "go together",
+		},
+		{
+			name:     "without TLS",
+			options:  LDAPOptions{TLS: "none", ClientCert: "client.crt", ClientKey: "client.key"},
+			expected: "require --ldap-tls",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := dialLDAP(&tt.options)
+			if err == nil {
+				conn.Close()
+				t.Fatal("dialLDAP() succeeded, want an error")
+			}
+			if !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("dialLDAP() = %v, want an error containing %q", err, tt.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// logger returns the logger of the run, slog.Default when none is set
+func (o *MigrateOptions) logger() *slog.Logger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	return slog.Default()
+}
+
+// logDebug logs a troubleshooting message, only emitted by loggers enabled at debug level
+func (o *MigrateOptions) logDebug(msg string, attrs ...any) {
+	o.logger().Debug(msg, attrs...)
+}
+
+// logInfo logs an informational message, attrs are slog key/value pairs
+func (o *MigrateOptions) logInfo(msg string, attrs ...any) {
+	o.logger().Info(msg, attrs...)
+}
+
+// logWarn logs a warning message, attrs are slog key/value pairs
+func (o *MigrateOptions) logWarn(msg string, attrs ...any) {
+	o.logger().Warn(msg, attrs...)
+}
+
+// logError logs an error message, attrs are slog key/value pairs
+func (o *MigrateOptions) logError(msg string, attrs ...any) {
+	o.logger().Error(msg, attrs...)
+}
+
+// interrupted returns an error naming the cause when ctx was cancelled, nil otherwise
+func interrupted(ctx context.Context) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("migration interrupted: %w", context.Cause(ctx))
+	}
+	return nil
+}
+
+// interruptedOr returns the interruption error when ctx was cancelled, err otherwise
+func interruptedOr(ctx context.Context, err error) error {
+	if ierr := interrupted(ctx); ierr != nil {
+		return ierr
+	}
+	return err
+}
@@ -0,0 +1,81 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// MigrationStats holds the counters of a migrate run, backing both the summary report and the metrics file
+type MigrationStats struct {
+	AccountsTotal       int
+	AccountsResolved    int
+	RoleBindingsMutated int
+	RoleBindingsSkipped int
+	AlreadyMigrated     int
+	OrphanNamespaces    int
+
+	RoleBindingsWritten   int
+	RoleBindingsCreated   int
+	RoleBindingsUpdated   int
+	RoleBindingsUnchanged int
+	RoleBindingsFailed    int
+	RoleBindingsPruned    int
+
+	DiffLinesAdded   int
+	DiffLinesRemoved int
+
+	LDAPCacheHits         int
+	LDAPNegativeCacheHits int
+}
+
+// WriteMetrics writes the counters in Prometheus textfile format, the file is written to a temporary
+// file first and renamed so node_exporter's textfile collector never reads a partial file
+func (s *MigrationStats) WriteMetrics(path string) error {
+	metrics := []struct {
+		name  string
+		help  string
+		value int
+	}{
+		{"accounts_total", "Number of UserAccounts found", s.AccountsTotal},
+		{"accounts_resolved", "Number of UserAccounts resolved to an sso identity", s.AccountsResolved},
+		{"rolebindings_mutated", "Number of RoleBindings migrated", s.RoleBindingsMutated},
+		{"rolebindings_skipped", "Number of RoleBindings skipped", s.RoleBindingsSkipped},
+		{"rolebindings_already_migrated", "Number of RoleBindings found already migrated", s.AlreadyMigrated},
+		{"orphan_namespaces", "Number of Tenant Namespaces left without migrated RoleBindings", s.OrphanNamespaces},
+	}
+
+	var sb strings.Builder
+	for _, m := range metrics {
+		fmt.Fprintf(&sb, "# HELP %s %s\n", m.name, m.help)
+		fmt.Fprintf(&sb, "# TYPE %s gauge\n", m.name)
+		fmt.Fprintf(&sb, "%s %d\n", m.name, m.value)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := tmp.Chmod(0644); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if _, err := tmp.WriteString(sb.String()); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
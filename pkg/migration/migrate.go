@@ -0,0 +1,2376 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+// Package migration migrates Konflux tenant RoleBindings from kubesaw users to sso users. It backs
+// the wscli migrate command and can be embedded in other programs through Run
+package migration
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"log/slog"
+	"maps"
+	"math"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"sigs.k8s.io/yaml"
+)
+
+// Transform resolves the id of a UserAccount from its email, or from the claim selected by a claim: transform
+type Transform func(o *MigrateOptions, email string, ctx context.Context) (string, error)
+
+// MigrateOptions holds the settings of a migrate run, each field mirrors the wscli migrate flag of the same name
+type MigrateOptions struct {
+	Target          string
+	Kubeconfig      string // empty for $KUBECONFIG, ~/.kube/config or the in-cluster config
+	OutputFile      string // - for stdout, empty to skip writing
+	NonUserSubjects string
+	SubjectKind     string
+	SubjectAPIGroup string
+	Namespaces      []string
+	MetricsFile     string
+	Verbose         bool
+
+	UserAccountNamespaces  []string
+	AllNamespaces          bool
+	OnlyUsers              string
+	ExpandGroups           bool
+	Apply                  bool
+	DryRun                 bool
+	RoleFilter             string
+	MappingReport          string
+	CreatedAfter           string
+	OutputFormat           string
+	LowercaseIDs           bool
+	MaxRoleBindings        int
+	Force                  bool
+	Append                 bool
+	TargetOverrides        map[string]string
+	NameTemplate           string
+	EmailClaim             string
+	ListMaxAttempts        int
+	RoleMap                string
+	ValidateRoles          string // 'off', 'warn' or 'error'
+	InputFile              string
+	IDMapFile              string
+	NamespaceReport        string
+	SubjectClaims          []string
+	OutputURL              string // replaces OutputFile when set
+	ExcludeSubjectRegex    []string
+	NSConcurrency          int
+	KeepGoing              bool
+	As                     string   // user to impersonate on every k8s call
+	AsGroups               []string // groups to impersonate, requires As
+	Diff                   bool     // requires DryRun
+	UnmappedRoles          string   // 'warn' or 'skip'
+	Prune                  bool     // requires Apply
+	PruneRecord            string
+	EmailCleanRegex        string // subaddress stripped from the emails, replaced with EmailCleanReplace
+	EmailCleanReplace      string
+	SamplePercent          float64 // share of the RoleBindings migrated, 100 for all
+	SampleSeed             int64
+	KeepAnnotationPrefixes []string
+	OwnerRef               map[string]string // apiVersion, kind, name and uid of the owner set on the migrated RoleBindings
+	MapOverrides           map[string]string // account name to fixed id replacing its resolution, empty to exclude it
+	RoleTransform          string            // 'regex => template' applied to the first match in the ClusterRole names
+	RequireAccounts        bool              // fail instead of warning when no UserAccount is listed
+	ReplaceAll             bool              // rename every match instead of the first one
+	Source                 string            // 'useraccount' or 'spacebinding'
+	Watch                  bool              // after the run, migrate and apply the Tenant RoleBindings created later until ctx is done
+	WatchRefresh           time.Duration     // interval between two idMap refreshes of Watch, 0 to never refresh
+	SpaceBindingNamespace  string
+	HostKubeconfig         string // host cluster of the SpaceBindings, empty for Kubeconfig
+	PreviewCount           int    // per-RoleBinding dry run lines logged, 0 for all
+	Transform              string // registered transform resolving the ids, replaces Target when set
+	OutputConfigMap        string // namespace/name of a ConfigMap also holding the output
+	MatchBy                string // 'name' or 'email', what the RoleBinding subjects are matched to
+	RequireRoleBindings    bool   // fail instead of warning when no RoleBinding is selected
+	ValidateIdentities     bool   // warn about the target users missing from the cluster
+	OutputDir              string // directory of the OutputByRole files
+	OutputByRole           bool   // one file per target role in OutputDir instead of OutputFile
+	OrphanReport           string // JSON or text file listing the orphan Tenant Namespaces
+	FailOnUnresolved       string // count, or percentage with a % suffix, of unresolved accounts aborting the run, empty to disable
+	OutputHeader           bool   // start the yaml, ssa and script outputs with a provenance comment block
+	EffectiveBindingsOnly  bool   // stop once the RoleBindings are migrated, nothing is written nor applied
+	ExtraSelector          string // label selector AND-combined with the Tenant RoleBinding one
+	ProxyURL               string // HTTP(S) proxy of the k8s connections, the HTTPS_PROXY and NO_PROXY environment when empty
+	Checkpoint             string // JSON file recording the resolved accounts and applied RoleBindings, resumed from when it exists
+	StrictSingleSubject    bool   // fail on RoleBindings with several subjects instead of splitting them
+
+	LDAP     LDAPOptions
+	Identity IdentityOptions // replaces LDAP when its URL is set
+
+	// Directory resolves the emails for the 'user' target instead of Identity or LDAP when set
+	Directory DirectoryLookup
+
+	// Logger receives the status messages of the run, slog.Default when nil
+	Logger *slog.Logger
+	// Progress is called with a done/total counter every progressInterval items, may be nil
+	Progress func(what string, done int, total int)
+	// ConfirmPrune is asked before Prune deletes count source RoleBindings, nothing is deleted when it is nil
+	ConfirmPrune func(count int) bool
+
+	allowedUsers       map[string]bool
+	roleMatcher        func(string) bool
+	createdAfter       time.Time
+	kubeContext        string
+	kubeCluster        string
+	startTime          time.Time
+	nameTemplate       *template.Template
+	roleMap            roleMap
+	outputURL          *url.URL
+	ownerRef           *metav1.OwnerReference
+	emailClean         *regexp.Regexp
+	roleTransform      *regexp.Regexp
+	transform          transformer
+	configMapNamespace string
+	configMapName      string
+	targetOverrides    map[string]transformer
+	roleTemplate       string
+	excludeSubjects    []*regexp.Regexp
+	// subjectAliases maps the values of each --subject-claim, in flag order, to their UserAccount name
+	subjectAliases []map[string]string
+	directory      DirectoryLookup
+	// ldapCache holds the user name found for each searched email, empty when none was found
+	ldapCache        map[string]string
+	prefetched       map[string]string // users resolved by prefetchUsers, keyed by cleaned email
+	ldapCacheHits    int
+	ldapNegativeHits int
+	checkpoint       *checkpoint
+	accountsTotal    int // accounts found, for the OutputHeader
+	// unresolvedLimit is the --fail-on-unresolved threshold, a percentage of the accounts when unresolvedPercent is set
+	unresolvedLimit   float64
+	unresolvedPercent bool
+	// diffOrigins holds the source RoleBindings by namespace/name before the split and expansion, for --diff
+	diffOrigins map[string]*rbacv1.RoleBinding
+}
+
+// Result holds the outcome of a migrate run
+type Result struct {
+	// RoleBindings are the migrated RoleBindings sorted by Namespace then Name
+	RoleBindings []rbacv1.RoleBinding
+	// Mappings records the source RoleBinding of each migrated one
+	Mappings []RoleBindingMapping
+	// Unresolved are the UserAccounts that could not be resolved to an sso id
+	Unresolved []string
+	// OrphanNamespaces are the Tenant Namespaces left without any migrated RoleBinding
+	OrphanNamespaces []string
+	// Namespaces breaks down the source RoleBindings of each Tenant Namespace, sorted by Namespace
+	Namespaces []NamespaceSummary
+	// Diffs are the unified diffs of the migrated RoleBindings with --diff, in Namespace order
+	Diffs []RoleBindingDiff
+	Stats MigrationStats
+}
+
+// ErrInvalidOptions is wrapped by the errors Run returns for invalid option values
+var ErrInvalidOptions = errors.New("invalid options")
+
+// DefaultEmailCleanRegex matches the +tag subaddress of an email along with the @ that follows it
+const DefaultEmailCleanRegex = `\+[^@]+@`
+
+// DefaultRoleTransform renames the appstudio ClusterRoles to their konflux counterpart
+const DefaultRoleTransform = "appstudio => konflux"
+
+// progressInterval is the number of processed items between two progress reports
+const progressInterval = 100
+
+// UserAccountGVR is the resource of the kubesaw UserAccounts
+var UserAccountGVR = schema.GroupVersionResource{
+	Group:    "toolchain.dev.openshift.com",
+	Version:  "v1alpha1",
+	Resource: "useraccounts",
+}
+
+// KubeClientConfig loads the kubeconfig the way kubectl does: kubeconfig when set, otherwise the files
+// listed in $KUBECONFIG merged together or ~/.kube/config, falling back to the in-cluster config
+func KubeClientConfig(kubeconfig string) clientcmd.ClientConfig {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	rules.ExplicitPath = kubeconfig
+
+	return clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{})
+}
+
+// RESTConfig loads the validated kubeconfig as the config every k8s client is built from, so listing and
+// applying both run as the --as identity and through the --proxy-url
+func (o *MigrateOptions) RESTConfig() (*rest.Config, error) {
+	if err := ValidateKubeconfig(o.Kubeconfig); err != nil {
+		return nil, err
+	}
+	config, err := KubeClientConfig(o.Kubeconfig).ClientConfig()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+
+	config.Impersonate = rest.ImpersonationConfig{UserName: o.As, Groups: o.AsGroups}
+	//Without --proxy-url client-go honors the proxy environment and the kubeconfig proxy-url
+	if o.ProxyURL != "" {
+		proxyURL, err := parseProxyURL(o.ProxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("--proxy-url: %w", err)
+		}
+		config.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	return config, nil
+}
+
+// ValidateKubeconfig checks that an explicit kubeconfig exists and that its current context, and the cluster
+// it references, are defined, so a wrong file or context is reported plainly instead of as a client-go error.
+// A kubeconfig without current context is left to client-go, which falls back to the in-cluster config
+func ValidateKubeconfig(kubeconfig string) error {
+	if kubeconfig != "" {
+		if _, err := os.Stat(kubeconfig); errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("kubeconfig %s does not exist, check --kubeconfig", kubeconfig)
+		}
+	}
+
+	rawConfig, err := KubeClientConfig(kubeconfig).RawConfig()
+	if err != nil {
+		return fmt.Errorf("failed to read kubeconfig: %w", err)
+	}
+	if rawConfig.CurrentContext == "" {
+		return nil
+	}
+
+	kubeContext, ok := rawConfig.Contexts[rawConfig.CurrentContext]
+	if !ok {
+		available := "none"
+		if len(rawConfig.Contexts) > 0 {
+			available = strings.Join(slices.Sorted(maps.Keys(rawConfig.Contexts)), ", ")
+		}
+		return fmt.Errorf("context %s not found in kubeconfig; available contexts: %s", rawConfig.CurrentContext, available)
+	}
+	if _, ok := rawConfig.Clusters[kubeContext.Cluster]; !ok {
+		return fmt.Errorf("context %s references cluster %q, which is not defined in kubeconfig", rawConfig.CurrentContext, kubeContext.Cluster)
+	}
+
+	return nil
+}
+
+// DefaultMigrateOptions returns the options of the wscli migrate defaults, with an empty Kubeconfig
+func DefaultMigrateOptions() MigrateOptions {
+	return MigrateOptions{
+		Target:                "user",
+		OutputFile:            "migrated_rolebindings.yaml",
+		NonUserSubjects:       "keep",
+		SubjectKind:           rbacv1.UserKind,
+		SubjectAPIGroup:       rbacv1.GroupName,
+		UserAccountNamespaces: []string{"toolchain-member-operator"},
+		OutputFormat:          "yaml",
+		OutputHeader:          true,
+		MaxRoleBindings:       5000,
+		NameTemplate:          DefaultNameTemplate,
+		EmailClaim:            "email",
+		ListMaxAttempts:       5,
+		ValidateRoles:         "off",
+		NSConcurrency:         1,
+		UnmappedRoles:         "warn",
+		PruneRecord:           DefaultPruneRecord,
+		EmailCleanRegex:       DefaultEmailCleanRegex,
+		EmailCleanReplace:     "@",
+		SamplePercent:         100,
+		RoleTransform:         DefaultRoleTransform,
+		Source:                "useraccount",
+		MatchBy:               "name",
+		SpaceBindingNamespace: "toolchain-host-operator",
+		WatchRefresh:          10 * time.Minute,
+		LDAP: LDAPOptions{
+			Host:          DefaultLDAPHost,
+			TLS:           "none",
+			MultipleMatch: "first",
+			Auth:          "none",
+			Krb5Conf:      DefaultKrb5Conf,
+		},
+		Identity: IdentityOptions{
+			IDField: DefaultIDJSONField,
+		},
+	}
+}
+
+// Run resolves the UserAccounts to sso ids and migrates the Tenant RoleBindings to them, writing and
+// applying the result as selected in opts. It returns the migrated RoleBindings instead of exiting on errors,
+// along with the progress made so far when ctx is cancelled or times out
+func Run(ctx context.Context, opts MigrateOptions) (Result, error) {
+	o := &opts
+	defer o.closeDirectory()
+
+	//The context is read first as --output-file may embed it
+	if rawConfig, err := KubeClientConfig(o.Kubeconfig).RawConfig(); err == nil {
+		o.kubeContext = rawConfig.CurrentContext
+		if kubeContext, ok := rawConfig.Contexts[rawConfig.CurrentContext]; ok {
+			o.kubeCluster = kubeContext.Cluster
+		}
+	}
+
+	if err := o.compile(); err != nil {
+		return Result{}, err
+	}
+	//Saving the progress made so far, most useful when the run is interrupted
+	defer func() {
+		if err := o.checkpoint.save(); err != nil {
+			o.logWarn(fmt.Sprintf("Failed to save --checkpoint %s: %v", o.Checkpoint, err), "file", o.Checkpoint, "error", err)
+		}
+	}()
+
+	//Offline runs from --input-file and --id-map-file only need the cluster to apply or validate
+	var config *rest.Config
+	if o.needsCluster() {
+		var err error
+		if config, err = o.RESTConfig(); err != nil {
+			return Result{}, err
+		}
+	}
+
+	var result Result
+	idMap, err := loadIDMap(config, o, &result, ctx)
+	if err != nil {
+		return result, err
+	}
+	o.accountsTotal = result.Stats.AccountsTotal
+
+	if err := migrate(idMap, config, o, &result, ctx); err != nil {
+		return result, err
+	}
+
+	if o.Watch {
+		return result, watchRoleBindings(idMap, config, o, &result, ctx)
+	}
+
+	return result, nil
+}
+
+// loadIDMap returns the ids of the accounts, read from --id-map-file or resolved from the UserAccounts,
+// counting them in result along with the unresolved accounts
+func loadIDMap(config *rest.Config, o *MigrateOptions, result *Result, ctx context.Context) (map[string]string, error) {
+	var idMap map[string]string
+	var err error
+	if o.IDMapFile != "" {
+		idMap, err = loadIDMapFile(o.IDMapFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --id-map-file: %w", err)
+		}
+		if o.LowercaseIDs {
+			for name, id := range idMap {
+				idMap[name] = strings.ToLower(id)
+			}
+		}
+		if o.MatchBy == "email" {
+			idMap = o.matchKeys(idMap)
+		}
+		o.logInfo(fmt.Sprintf("Loaded %d account ids from %s", len(idMap), o.IDMapFile), "count", len(idMap), "file", o.IDMapFile)
+		result.Stats.AccountsTotal = len(idMap)
+	} else {
+		idMap, result.Unresolved, result.Stats.AccountsTotal, err = resolveAccounts(config, o, ctx)
+		result.Stats.AccountsResolved = len(idMap)
+		result.Stats.LDAPCacheHits = o.ldapCacheHits
+		result.Stats.LDAPNegativeCacheHits = o.ldapNegativeHits
+		if err != nil {
+			return nil, err
+		}
+		//Checked before listing the RoleBindings so nothing is written or applied
+		if err := o.checkUnresolved(result.Unresolved, result.Stats.AccountsTotal); err != nil {
+			return nil, err
+		}
+	}
+	result.Stats.AccountsResolved = len(idMap)
+
+	return idMap, nil
+}
+
+// needsCluster reports whether the run reads from or writes to the cluster
+func (o *MigrateOptions) needsCluster() bool {
+	return o.InputFile == "" || o.IDMapFile == "" || o.Apply || o.ExpandGroups || o.ValidateRoles != "off" || o.OutputConfigMap != "" || o.ValidateIdentities
+}
+
+// resolveAccounts lists the UserAccounts and resolves them to their ids, returning the map of the
+// resolved ones, the names of the unresolved ones and the number of accounts found
+func resolveAccounts(config *rest.Config, o *MigrateOptions, ctx context.Context) (map[string]string, []string, int, error) {
+	defer o.closeDirectory()
+
+	//Init dynamic client
+	dynclient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, nil, 0, fmt.Errorf("failed to create k8s client: %w", err)
+	}
+
+	//SpaceBinding MasterUserRecords share the name and claims of their UserAccounts
+	var userAccounts *unstructured.UnstructuredList
+	if o.Source == "spacebinding" {
+		userAccounts, err = listSpaceBindingAccounts(config, o, ctx)
+	} else {
+		userAccounts, err = listUserAccounts(dynclient, o, ctx)
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	if o.Verbose {
+		o.logAccountsPerNamespace(userAccounts)
+	}
+
+	switch {
+	case o.Transform != "":
+		o.logInfo(fmt.Sprintf("migrate called for transform %s", o.Transform), "transform", o.Transform)
+	case o.Target == "email":
+		o.logInfo("migrate called for email", "target", o.Target)
+	case o.Target == "user":
+		o.logInfo("migrate called for user name", "target", o.Target)
+	}
+
+	idMap, unresolved, err := buildIDMap(userAccounts, o.transform, o, ctx)
+
+	return idMap, unresolved, len(userAccounts.Items), err
+}
+
+// listUserAccounts lists the UserAccounts of the --useraccount-namespace namespaces, or of all of them
+func listUserAccounts(dynclient dynamic.Interface, o *MigrateOptions, ctx context.Context) (*unstructured.UnstructuredList, error) {
+	uaNamespaces := o.UserAccountNamespaces
+	where := fmt.Sprintf("in %s namespace", strings.Join(uaNamespaces, ", "))
+	if len(uaNamespaces) > 1 {
+		where = fmt.Sprintf("in %s namespaces", strings.Join(uaNamespaces, ", "))
+	}
+	if o.AllNamespaces {
+		uaNamespaces = []string{metav1.NamespaceAll}
+		where = "across all namespaces"
+	}
+
+	userAccounts := &unstructured.UnstructuredList{}
+	for _, uaNamespace := range uaNamespaces {
+		var list *unstructured.UnstructuredList
+		err := listWithRetry("user accounts", o, ctx, func() (err error) {
+			list, err = dynclient.Resource(UserAccountGVR).Namespace(uaNamespace).List(ctx, metav1.ListOptions{})
+			return err
+		})
+		if err != nil {
+			return nil, interruptedOr(ctx, fmt.Errorf("failed to list user accounts in %q namespace: %w", uaNamespace, err))
+		}
+		userAccounts.Items = append(userAccounts.Items, list.Items...)
+	}
+	if len(uaNamespaces) > 1 {
+		userAccounts.Items = o.dedupUserAccounts(userAccounts.Items)
+	}
+
+	o.logInfo(fmt.Sprintf("Found %d user accounts %s:", len(userAccounts.Items), where), "count", len(userAccounts.Items), "namespaces", uaNamespaces)
+
+	if len(userAccounts.Items) == 0 {
+		//An empty list is rarely real, the source is usually wrong or hidden by RBAC
+		if o.RequireAccounts {
+			return nil, fmt.Errorf("no user accounts found %s, check --useraccount-namespace, the %s resource and the RBAC to list it", where, UserAccountGVR.GroupResource())
+		}
+		o.logWarn(fmt.Sprintf("No user accounts found %s, nothing will be migrated. Check --useraccount-namespace, the %s resource and the RBAC to list it", where, UserAccountGVR.GroupResource()), "namespaces", uaNamespaces, "resource", UserAccountGVR.GroupResource().String())
+	}
+
+	return userAccounts, nil
+}
+
+func (o *MigrateOptions) compile() error {
+	if _, ok := targetTransforms[o.Target]; !ok {
+		return fmt.Errorf("%w: select 'email' or 'user' as the target identity attribute with the -t Flag", ErrInvalidOptions)
+	}
+
+	if o.EmailClaim == "" {
+		return fmt.Errorf("%w: --email-claim must not be empty", ErrInvalidOptions)
+	}
+
+	if !slices.Contains([]string{"off", "warn", "error"}, o.ValidateRoles) {
+		return fmt.Errorf("%w: select 'off', 'warn' or 'error' for the --validate-roles Flag", ErrInvalidOptions)
+	}
+
+	if !slices.Contains([]string{"error", "first", "skip"}, o.LDAP.MultipleMatch) {
+		return fmt.Errorf("%w: select 'error', 'first' or 'skip' for the --ldap-multiple-match Flag", ErrInvalidOptions)
+	}
+
+	if !slices.Contains([]string{"", "none", "simple", "gssapi"}, o.LDAP.Auth) {
+		return fmt.Errorf("%w: select 'none', 'simple' or 'gssapi' for the --ldap-auth Flag", ErrInvalidOptions)
+	}
+	if o.LDAP.Auth == "simple" && o.LDAP.BindDN == "" {
+		return fmt.Errorf("%w: --ldap-auth simple requires --ldap-bind-dn", ErrInvalidOptions)
+	}
+	if o.LDAP.BindPasswordFile != "" && o.LDAP.BindPasswordStdin {
+		return fmt.Errorf("%w: --ldap-bind-password-file and --ldap-bind-password-stdin are mutually exclusive", ErrInvalidOptions)
+	}
+
+	if o.LDAP.BatchSize < 0 {
+		return fmt.Errorf("%w: --ldap-batch-size must not be negative", ErrInvalidOptions)
+	}
+
+	if o.ProxyURL != "" {
+		if _, err := parseProxyURL(o.ProxyURL); err != nil {
+			return fmt.Errorf("%w: --proxy-url: %w", ErrInvalidOptions, err)
+		}
+	}
+
+	if o.LDAP.ProxyURL != "" {
+		if _, err := parseSOCKSURL(o.LDAP.ProxyURL); err != nil {
+			return fmt.Errorf("%w: --ldap-proxy-url: %w", ErrInvalidOptions, err)
+		}
+	}
+
+	if o.Identity.URL != "" {
+		if err := o.Identity.validate(); err != nil {
+			return fmt.Errorf("%w: --identity-url: %w", ErrInvalidOptions, err)
+		}
+	}
+
+	if o.OutputURL != "" {
+		outputURL, err := parseOutputURL(o.OutputURL)
+		if err != nil {
+			return fmt.Errorf("%w: --output-url: %w", ErrInvalidOptions, err)
+		}
+		o.outputURL = outputURL
+	}
+
+	o.startTime = time.Now()
+	outputFile, err := o.renderOutputFile(o.startTime)
+	if err != nil {
+		return fmt.Errorf("%w: --output-file: %w", ErrInvalidOptions, err)
+	}
+	o.OutputFile = outputFile
+
+	//A file:// URL is a local --output-file, opened and guarded like one
+	if o.outputURL != nil && o.outputURL.Scheme == "file" {
+		if o.outputURL.Path == "" {
+			return fmt.Errorf("%w: --output-url: expected file:///path, got %s", ErrInvalidOptions, o.OutputURL)
+		}
+		o.OutputFile = o.outputURL.Path
+		o.outputURL = nil
+	}
+
+	if o.OutputByRole != (o.OutputDir != "") {
+		return fmt.Errorf("%w: --output-by-role and --output-dir go together", ErrInvalidOptions)
+	}
+	if o.OutputByRole && o.OutputURL != "" {
+		return fmt.Errorf("%w: --output-by-role and --output-url are mutually exclusive", ErrInvalidOptions)
+	}
+
+	//Checked upfront so a protected output file does not waste a whole run
+	if o.outputURL == nil && !o.OutputByRole && !o.EffectiveBindingsOnly && o.OutputFile != "" && o.OutputFile != "-" && !o.Force && !o.Append {
+		if _, err := os.Stat(o.OutputFile); err == nil {
+			return fmt.Errorf("refusing to overwrite existing --output-file %s, pass --force to overwrite it or --append to add to it", o.OutputFile)
+		}
+	}
+
+	if o.ListMaxAttempts < 1 {
+		return fmt.Errorf("%w: --list-max-attempts must be at least 1", ErrInvalidOptions)
+	}
+
+	if o.MatchBy != "name" && o.MatchBy != "email" {
+		return fmt.Errorf("%w: select 'name' or 'email' for the --match-by Flag", ErrInvalidOptions)
+	}
+
+	if o.Source != "useraccount" && o.Source != "spacebinding" {
+		return fmt.Errorf("%w: select 'useraccount' or 'spacebinding' for the --source Flag", ErrInvalidOptions)
+	}
+	//The SpaceBindings replace the Tenant RoleBindings, there is no source RoleBinding to read, prune or watch
+	if o.Source == "spacebinding" && (o.InputFile != "" || o.Prune || o.Watch) {
+		return fmt.Errorf("%w: --source spacebinding derives the RoleBindings from the SpaceBindings, drop --input-file, --prune and --watch", ErrInvalidOptions)
+	}
+
+	if o.UnmappedRoles != "warn" && o.UnmappedRoles != "skip" {
+		return fmt.Errorf("%w: select 'warn' or 'skip' for the --unmapped-roles Flag", ErrInvalidOptions)
+	}
+
+	emailClean, err := regexp.Compile(o.EmailCleanRegex)
+	if err != nil {
+		return fmt.Errorf("%w: --email-clean-regex: %w", ErrInvalidOptions, err)
+	}
+	o.emailClean = emailClean
+
+	roleTransform, roleTemplate, ok := strings.Cut(o.RoleTransform, "=>")
+	if !ok {
+		return fmt.Errorf("%w: --role-transform must be of the form 'regex => template'", ErrInvalidOptions)
+	}
+	o.roleTransform, err = regexp.Compile(strings.TrimSpace(roleTransform))
+	if err != nil {
+		return fmt.Errorf("%w: --role-transform: %w", ErrInvalidOptions, err)
+	}
+	o.roleTemplate = strings.TrimSpace(roleTemplate)
+
+	if o.SamplePercent <= 0 || o.SamplePercent > 100 {
+		return fmt.Errorf("%w: --sample-percent must be above 0 and at most 100", ErrInvalidOptions)
+	}
+
+	if o.EffectiveBindingsOnly && (o.Apply || o.OutputConfigMap != "") {
+		return fmt.Errorf("%w: --print-effective-bindings-only does not write to the cluster, drop --apply and --output-configmap", ErrInvalidOptions)
+	}
+
+	if o.Prune && !o.Apply {
+		return fmt.Errorf("%w: --prune requires --apply", ErrInvalidOptions)
+	}
+	if o.Watch && (!o.Apply || o.InputFile != "" || o.Prune) {
+		return fmt.Errorf("%w: --watch requires --apply and a cluster source, drop --input-file and --prune", ErrInvalidOptions)
+	}
+	if o.WatchRefresh < 0 {
+		return fmt.Errorf("%w: --watch-refresh must not be negative", ErrInvalidOptions)
+	}
+
+	if o.Prune && o.PruneRecord == "" {
+		return fmt.Errorf("%w: --prune-record must not be empty", ErrInvalidOptions)
+	}
+
+	if o.OutputConfigMap != "" {
+		o.configMapNamespace, o.configMapName, err = parseConfigMapRef(o.OutputConfigMap)
+		if err != nil {
+			return fmt.Errorf("%w: --output-configmap: %w", ErrInvalidOptions, err)
+		}
+	}
+
+	if o.FailOnUnresolved != "" {
+		o.unresolvedLimit, o.unresolvedPercent, err = parseUnresolvedThreshold(o.FailOnUnresolved)
+		if err != nil {
+			return fmt.Errorf("%w: --fail-on-unresolved: %w", ErrInvalidOptions, err)
+		}
+	}
+
+	if o.PreviewCount < 0 {
+		return fmt.Errorf("%w: --preview-count must not be negative", ErrInvalidOptions)
+	}
+
+	if o.Diff && !o.DryRun {
+		return fmt.Errorf("%w: --diff requires --dry-run", ErrInvalidOptions)
+	}
+
+	if len(o.OwnerRef) > 0 {
+		ownerRef, err := parseOwnerRef(o.OwnerRef)
+		if err != nil {
+			return fmt.Errorf("%w: --owner-ref: %w", ErrInvalidOptions, err)
+		}
+		o.ownerRef = ownerRef
+	}
+
+	if len(o.AsGroups) > 0 && o.As == "" {
+		return fmt.Errorf("%w: --as-group requires --as", ErrInvalidOptions)
+	}
+
+	if o.ExtraSelector != "" {
+		if _, err := labels.Parse(o.ExtraSelector); err != nil {
+			return fmt.Errorf("%w: --extra-rolebinding-selector: %w", ErrInvalidOptions, err)
+		}
+	}
+
+	if o.NSConcurrency < 1 {
+		return fmt.Errorf("%w: --ns-concurrency must be at least 1", ErrInvalidOptions)
+	}
+
+	if !slices.Contains(subjectKinds, o.SubjectKind) {
+		return fmt.Errorf("%w: select %s for the --subject-kind Flag", ErrInvalidOptions, quoteList(subjectKinds))
+	}
+	if !slices.Contains(subjectAPIGroups, o.SubjectAPIGroup) {
+		return fmt.Errorf("%w: select %s for the --subject-apigroup Flag", ErrInvalidOptions, quoteList(subjectAPIGroups))
+	}
+
+	if o.NonUserSubjects != "keep" && o.NonUserSubjects != "skip" {
+		return fmt.Errorf("%w: select 'keep' or 'skip' for the --non-user-subjects Flag", ErrInvalidOptions)
+	}
+
+	allowedUsers, err := loadAllowedUsers(o.OnlyUsers)
+	if err != nil {
+		return fmt.Errorf("failed to load --only-users list: %w", err)
+	}
+	o.allowedUsers = allowedUsers
+
+	roleMatcher, err := compileRoleFilter(o.RoleFilter)
+	if err != nil {
+		return fmt.Errorf("%w: --role-filter: %w", ErrInvalidOptions, err)
+	}
+	o.roleMatcher = roleMatcher
+
+	o.excludeSubjects = make([]*regexp.Regexp, 0, len(o.ExcludeSubjectRegex))
+	for _, pattern := range o.ExcludeSubjectRegex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("%w: --exclude-subject-regex %q: %w", ErrInvalidOptions, pattern, err)
+		}
+		o.excludeSubjects = append(o.excludeSubjects, re)
+	}
+
+	if o.CreatedAfter != "" {
+		createdAfter, err := time.Parse(time.RFC3339, o.CreatedAfter)
+		if err != nil {
+			return fmt.Errorf("%w: --created-after, expected an RFC3339 time: %w", ErrInvalidOptions, err)
+		}
+		o.createdAfter = createdAfter
+	}
+
+	transformName := targetTransforms[o.Target]
+	if o.Transform != "" {
+		transformName = o.Transform
+	}
+	o.transform, err = o.lookupTransform(transformName)
+	if err != nil {
+		return fmt.Errorf("%w: --transform: %w", ErrInvalidOptions, err)
+	}
+
+	o.targetOverrides = make(map[string]transformer, len(o.TargetOverrides))
+	for name, override := range o.TargetOverrides {
+		o.targetOverrides[name], err = o.lookupTransform(override)
+		if err != nil {
+			return fmt.Errorf("%w: --target-override for account %s: %w", ErrInvalidOptions, name, err)
+		}
+	}
+
+	roleMap, err := loadRoleMap(o.RoleMap)
+	if err != nil {
+		return fmt.Errorf("failed to load --role-map: %w", err)
+	}
+	o.roleMap = roleMap
+
+	o.checkpoint, err = loadCheckpoint(o.Checkpoint)
+	if err != nil {
+		return fmt.Errorf("failed to load --checkpoint: %w", err)
+	}
+
+	nameTemplate, err := template.New("name").Funcs(o.nameTemplateFuncs()).Option("missingkey=error").Parse(o.NameTemplate)
+	if err != nil {
+		return fmt.Errorf("%w: --name-template: %w", ErrInvalidOptions, err)
+	}
+	o.nameTemplate = nameTemplate
+
+	if _, ok := outputFormats[o.OutputFormat]; !ok {
+		return fmt.Errorf("%w: select one of %s for the --output-format Flag", ErrInvalidOptions, strings.Join(outputFormatNames(), ", "))
+	}
+
+	return nil
+}
+
+// logAccountsPerNamespace logs how many UserAccounts were found in each namespace
+func (o *MigrateOptions) logAccountsPerNamespace(userAccounts *unstructured.UnstructuredList) {
+	perNamespace := make(map[string]int)
+	for _, account := range userAccounts.Items {
+		perNamespace[account.GetNamespace()]++
+	}
+
+	for _, ns := range slices.Sorted(maps.Keys(perNamespace)) {
+		o.logInfo(fmt.Sprintf("  %s: %d", ns, perNamespace[ns]), "namespace", ns, "count", perNamespace[ns])
+	}
+}
+
+// cleanEmail strips the subaddress from email with the --email-clean-regex replacement
+func (o *MigrateOptions) cleanEmail(email string) string {
+	cEmail := o.emailClean.ReplaceAllString(email, o.EmailCleanReplace)
+
+	return cEmail
+}
+
+// transformRole expands --role-transform over the first match in the ClusterRole name, keeping the rest of it,
+// or over every match when ReplaceAll is set
+func (o *MigrateOptions) transformRole(role string) string {
+	if o.ReplaceAll {
+		return o.roleTransform.ReplaceAllString(role, o.roleTemplate)
+	}
+	match := o.roleTransform.FindStringSubmatchIndex(role)
+	if match == nil {
+		return role
+	}
+	expanded := o.roleTransform.ExpandString(nil, o.roleTemplate, role, match)
+
+	return role[:match[0]] + string(expanded) + role[match[1]:]
+}
+
+// subjectKinds are the --subject-kind values an sso identity can be bound as
+var subjectKinds = []string{rbacv1.UserKind, rbacv1.GroupKind}
+
+// subjectAPIGroups are the --subject-apigroup values: the RBAC group of Kubernetes and current OpenShift,
+// the user group and the empty group of the legacy OpenShift authorization
+var subjectAPIGroups = []string{rbacv1.GroupName, "user.openshift.io", ""}
+
+// quoteList formats values as a quoted, comma separated list for the messages
+func quoteList(values []string) string {
+	quoted := make([]string, 0, len(values))
+	for _, value := range values {
+		quoted = append(quoted, fmt.Sprintf("'%s'", value))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// targetTransforms maps the --target values to the registered transform they stand for
+var targetTransforms = map[string]string{
+	"email": "clean-email",
+	"user":  "ldap-uid",
+}
+
+// awkwardSubjectChars matches the characters that parse in an email but need quoting in kubectl and shell scripts
+var awkwardSubjectChars = regexp.MustCompile(`[^A-Za-z0-9._@-]`)
+
+// cleanEmailTransform adapts cleanEmail to the Transform type, leaving the emails that are not
+// a plain address unresolved rather than binding them as a broken subject
+func cleanEmailTransform(o *MigrateOptions, email string, ctx context.Context) (string, error) {
+	cEmail := o.cleanEmail(email)
+
+	address, err := mail.ParseAddress(cEmail)
+	if err != nil || address.Address != cEmail {
+		o.logWarn(fmt.Sprintf("Email %q is not a valid address, leaving it unresolved", cEmail), "email", cEmail)
+		return "", nil
+	}
+	if chars := awkwardSubjectChars.FindAllString(cEmail, -1); chars != nil {
+		o.logWarn(fmt.Sprintf("Email %s contains %q, which will need quoting as a RoleBinding subject", cEmail, strings.Join(slices.Compact(slices.Sorted(slices.Values(chars))), "")), "email", cEmail)
+	}
+
+	return cEmail, nil
+}
+
+// dedupUserAccounts keeps the first of the UserAccounts sharing a name across the --useraccount-namespace
+// namespaces, warning when the dropped ones carry another email
+func (o *MigrateOptions) dedupUserAccounts(accounts []unstructured.Unstructured) []unstructured.Unstructured {
+	kept := make(map[string]*unstructured.Unstructured)
+	deduped := make([]unstructured.Unstructured, 0, len(accounts))
+	for i := range accounts {
+		account := &accounts[i]
+		name := account.GetName()
+		first, ok := kept[name]
+		if !ok {
+			kept[name] = account
+			deduped = append(deduped, *account)
+			continue
+		}
+
+		firstEmail, _, _ := unstructured.NestedFieldNoCopy(first.Object, "spec", "propagatedClaims", o.EmailClaim)
+		email, _, _ := unstructured.NestedFieldNoCopy(account.Object, "spec", "propagatedClaims", o.EmailClaim)
+		if fmt.Sprint(firstEmail) != fmt.Sprint(email) {
+			o.logWarn(fmt.Sprintf("UserAccount %s is in both %s and %s namespaces with different emails %v and %v, keeping the one in %s", name, first.GetNamespace(), account.GetNamespace(), firstEmail, email, first.GetNamespace()), "account", name, "namespace", first.GetNamespace(), "duplicate", account.GetNamespace())
+		}
+	}
+
+	return deduped
+}
+
+// coerceEmail returns the email claim as a string, scalar values are formatted while nested structures are rejected
+func coerceEmail(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64, float64, bool:
+		return fmt.Sprint(v), nil
+	default:
+		return "", fmt.Errorf("email claim has unexpected type %T", value)
+	}
+}
+
+// buildIDMap resolves the UserAccounts to their ids, returning the names of the accounts that could not be resolved
+func buildIDMap(userAccounts *unstructured.UnstructuredList, transform transformer, opts *MigrateOptions, ctx context.Context) (map[string]string, []string, error) {
+	idMap := make(map[string]string)
+	var unresolved []string
+	claimFound := false
+	overrides := 0
+	opts.subjectAliases = make([]map[string]string, len(opts.SubjectClaims))
+	for i := range opts.subjectAliases {
+		opts.subjectAliases[i] = make(map[string]string)
+	}
+	//Resolving the directory emails upfront in batches saves a round-trip per account
+	if err := opts.prefetchUsers(opts.directoryEmails(userAccounts, transform), ctx); err != nil {
+		return idMap, unresolved, err
+	}
+
+	for i, account := range userAccounts.Items {
+		//Keeping the accounts resolved so far to report the progress made
+		if err := interrupted(ctx); err != nil {
+			return idMap, unresolved, err
+		}
+
+		opts.reportProgress("Resolving accounts", i+1, len(userAccounts.Items))
+		name := account.GetName()
+		if opts.isExcludedSubject(name) {
+			//Excluded accounts are never migrated, so there is no need to look them up
+			if opts.Verbose {
+				opts.logInfo(fmt.Sprintf("UserAccount %s: matches --exclude-subject-regex, not resolving it", name), "account", name)
+			}
+			continue
+		}
+		if id, overridden := opts.MapOverrides[name]; overridden {
+			applied, err := opts.overrideID(idMap, account, id)
+			if err != nil {
+				opts.logWarn(fmt.Sprintf("UserAccount %s: %v", name, err), "account", name)
+				unresolved = append(unresolved, name)
+			}
+			if applied {
+				overrides++
+			}
+			continue
+		}
+		spec, ok := account.Object["spec"].(map[string]interface{})
+		if !ok {
+			opts.logWarn(fmt.Sprintf("UserAccount %s: spec not found", name), "account", name)
+			unresolved = append(unresolved, name)
+			continue
+		}
+
+		claims, ok := spec["propagatedClaims"].(map[string]interface{})
+		if !ok {
+			opts.logWarn(fmt.Sprintf("UserAccount %s: claims not found", name), "account", name)
+			unresolved = append(unresolved, name)
+			continue
+		}
+
+		accountTransform := transform
+		if override, exists := opts.targetOverrides[name]; exists {
+			accountTransform = override
+			if opts.Verbose {
+				opts.logInfo(fmt.Sprintf("UserAccount %s: using target override %s", name, override.name), "account", name, "target", override.name)
+			}
+		}
+
+		rawEmail, ok := claims[accountTransform.claim]
+		if ok {
+			claimFound = true
+		}
+		if !ok || rawEmail == nil {
+			opts.logWarn(fmt.Sprintf("UserAccount %s: %s claim not found", name, accountTransform.claim), "account", name, "claim", accountTransform.claim)
+			unresolved = append(unresolved, name)
+			continue
+		}
+
+		email, err := coerceEmail(rawEmail)
+		if err != nil {
+			opts.logWarn(fmt.Sprintf("UserAccount %s: %v", name, err), "account", name, "type", fmt.Sprintf("%T", rawEmail))
+			unresolved = append(unresolved, name)
+			continue
+		}
+
+		//Accounts resolved before a restart are not looked up again
+		id, checkpointed := opts.checkpoint.account(accountTransform.name, name)
+		if !checkpointed {
+			id, err = accountTransform.transform(opts, email, ctx)
+			if err != nil {
+				return idMap, unresolved, err
+			}
+			if err := opts.checkpoint.recordAccount(accountTransform.name, name, id); err != nil {
+				return idMap, unresolved, fmt.Errorf("failed to save --checkpoint: %w", err)
+			}
+		}
+		if opts.LowercaseIDs {
+			id = strings.ToLower(id)
+		}
+
+		//Subjects holding emails are matched by the cleaned email of the account instead of its name
+		key := name
+		if opts.MatchBy == "email" {
+			accountEmail, _ := claims[opts.EmailClaim].(string)
+			key = opts.matchKey(accountEmail)
+		}
+
+		if id == "" || key == "" { //no need to map if empty since id was not found
+			unresolved = append(unresolved, name)
+			continue
+		}
+		idMap[key] = id
+
+		//Claim values only alias the account, the first account claiming a value keeps it
+		for i, claim := range opts.SubjectClaims {
+			if alias, ok := claims[claim].(string); ok && alias != "" {
+				if _, exists := opts.subjectAliases[i][alias]; !exists {
+					opts.subjectAliases[i][alias] = key
+				}
+			}
+		}
+	}
+
+	if len(opts.MapOverrides) > 0 {
+		opts.logInfo(fmt.Sprintf("Applied %d of %d --map-override entries", overrides, len(opts.MapOverrides)), "applied", overrides, "total", len(opts.MapOverrides))
+		for _, name := range slices.Sorted(maps.Keys(opts.MapOverrides)) {
+			if !slices.ContainsFunc(userAccounts.Items, func(account unstructured.Unstructured) bool { return account.GetName() == name }) {
+				opts.logWarn(fmt.Sprintf("--map-override %s matches no UserAccount", name), "account", name)
+			}
+		}
+	}
+
+	if !claimFound && len(userAccounts.Items) > 0 {
+		opts.logWarn(fmt.Sprintf("No UserAccount has a %s claim, check --email-claim or --transform", transform.claim), "claim", transform.claim)
+	}
+
+	return idMap, unresolved, nil
+}
+
+// parseUnresolvedThreshold parses a --fail-on-unresolved count, or percentage when suffixed with %
+func parseUnresolvedThreshold(threshold string) (float64, bool, error) {
+	value, percent := strings.CutSuffix(strings.TrimSpace(threshold), "%")
+	limit, err := strconv.ParseFloat(value, 64)
+	if err != nil || limit < 0 {
+		return 0, false, fmt.Errorf("expected a non-negative count or percentage such as 10 or 5%%, got %q", threshold)
+	}
+	if !percent && limit != math.Trunc(limit) {
+		return 0, false, fmt.Errorf("a count must be a whole number, got %q", threshold)
+	}
+	if percent && limit > 100 {
+		return 0, false, fmt.Errorf("a percentage must be at most 100%%, got %q", threshold)
+	}
+
+	return limit, percent, nil
+}
+
+// checkUnresolved fails when more of the total accounts are unresolved than --fail-on-unresolved allows,
+// listing them as a high count usually comes from a directory or configuration problem
+func (o *MigrateOptions) checkUnresolved(unresolved []string, total int) error {
+	if o.FailOnUnresolved == "" {
+		return nil
+	}
+
+	exceeded := float64(len(unresolved)) > o.unresolvedLimit
+	if o.unresolvedPercent {
+		exceeded = total > 0 && float64(len(unresolved))*100/float64(total) > o.unresolvedLimit
+	}
+	if !exceeded {
+		return nil
+	}
+
+	return fmt.Errorf("%d of %d accounts are unresolved, more than --fail-on-unresolved %s: %s", len(unresolved), total, o.FailOnUnresolved, strings.Join(unresolved, ", "))
+}
+
+// overrideID maps account to the --map-override id instead of resolving it, an empty id excludes the account.
+// It reports whether the override was applied, with --match-by email the account needs an email to be keyed by
+func (o *MigrateOptions) overrideID(idMap map[string]string, account unstructured.Unstructured, id string) (bool, error) {
+	name := account.GetName()
+	if id == "" {
+		o.logInfo(fmt.Sprintf("UserAccount %s: excluded by --map-override", name), "account", name)
+		return true, nil
+	}
+
+	key := name
+	if o.MatchBy == "email" {
+		email, _, _ := unstructured.NestedString(account.Object, "spec", "propagatedClaims", o.EmailClaim)
+		if key = o.matchKey(email); key == "" {
+			return false, fmt.Errorf("--map-override needs the %s claim to match subjects by email", o.EmailClaim)
+		}
+	}
+	idMap[key] = id
+	o.logInfo(fmt.Sprintf("UserAccount %s: mapped to %s by --map-override", name, id), "account", name, "id", id)
+
+	return true, nil
+}
+
+// directoryEmails returns the distinct cleaned emails of the accounts resolved through the directory, in account order
+func (o *MigrateOptions) directoryEmails(userAccounts *unstructured.UnstructuredList, transform transformer) []string {
+	var emails []string
+	seen := make(map[string]bool)
+	for _, account := range userAccounts.Items {
+		name := account.GetName()
+		accountTransform := transform
+		if override, exists := o.targetOverrides[name]; exists {
+			accountTransform = override
+		}
+		if _, checkpointed := o.checkpoint.account(accountTransform.name, name); checkpointed {
+			continue
+		}
+		if _, overridden := o.MapOverrides[name]; overridden {
+			continue
+		}
+		if accountTransform.name != "ldap-uid" || o.isExcludedSubject(name) {
+			continue
+		}
+
+		rawEmail, _, _ := unstructured.NestedFieldNoCopy(account.Object, "spec", "propagatedClaims", accountTransform.claim)
+		email, err := coerceEmail(rawEmail)
+		if rawEmail == nil || err != nil {
+			continue
+		}
+		if cEmail := o.cleanEmail(email); !seen[cEmail] {
+			seen[cEmail] = true
+			emails = append(emails, cEmail)
+		}
+	}
+
+	return emails
+}
+
+// matchKey returns the idMap key a RoleBinding subject or UserAccount is matched by: the name itself,
+// or the lowercased cleaned email with --match-by email
+func (o *MigrateOptions) matchKey(subject string) string {
+	if o.MatchBy != "email" {
+		return subject
+	}
+	return strings.ToLower(o.cleanEmail(strings.TrimSpace(subject)))
+}
+
+// matchKeys returns idMap keyed by the matchKey of its keys
+func (o *MigrateOptions) matchKeys(idMap map[string]string) map[string]string {
+	keyed := make(map[string]string, len(idMap))
+	for key, id := range idMap {
+		keyed[o.matchKey(key)] = id
+	}
+	return keyed
+}
+
+// lookupID returns the id of a RoleBinding subject, tried by its matchKey first then as
+// a value of the --subject-claim keys in the order they were given
+func (o *MigrateOptions) lookupID(idMap map[string]string, subject string) (string, bool) {
+	if id, ok := idMap[o.matchKey(subject)]; ok {
+		return id, true
+	}
+
+	for _, aliases := range o.subjectAliases {
+		if name, ok := aliases[subject]; ok {
+			if id, ok := idMap[name]; ok {
+				return id, true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func getTenantNamespaces(clientset kubernetes.Interface, opts *MigrateOptions, ctx context.Context) ([]string, error) {
+	//Get Namespaces
+	labelSelector := "toolchain.dev.openshift.com/type=tenant"
+
+	var ns *corev1.NamespaceList
+	err := listWithRetry("namespaces", opts, ctx, func() (err error) {
+		ns, err = clientset.CoreV1().Namespaces().List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+		return err
+	})
+	if err != nil {
+		return nil, interruptedOr(ctx, fmt.Errorf("failed to list namespace: %w", err))
+	}
+
+	nsNum := len(ns.Items)
+
+	tenantNamespaces := make([]string, 0, nsNum)
+
+	for _, namespace := range ns.Items {
+		nsName := namespace.Name
+		if !opts.isSelectedNamespace(nsName) {
+			continue
+		}
+		tenantNamespaces = append(tenantNamespaces, nsName)
+	}
+
+	return tenantNamespaces, nil
+}
+
+// reportProgress calls Progress with a "<what> done/total" counter every progressInterval items and on the last one
+func (o *MigrateOptions) reportProgress(what string, done int, total int) {
+	if o.Progress == nil {
+		return
+	}
+
+	if done%progressInterval == 0 || done == total {
+		o.Progress(what, done, total)
+	}
+}
+
+// parseOwnerRef builds the --owner-ref owner reference, all of apiVersion, kind, name and uid are required
+func parseOwnerRef(fields map[string]string) (*metav1.OwnerReference, error) {
+	for key := range fields {
+		if !slices.Contains([]string{"apiVersion", "kind", "name", "uid"}, key) {
+			return nil, fmt.Errorf("unknown field %q, select among apiVersion, kind, name and uid", key)
+		}
+	}
+	for _, key := range []string{"apiVersion", "kind", "name", "uid"} {
+		if fields[key] == "" {
+			return nil, fmt.Errorf("%s is required", key)
+		}
+	}
+	if _, err := schema.ParseGroupVersion(fields["apiVersion"]); err != nil {
+		return nil, err
+	}
+	if errs := validation.IsDNS1123Subdomain(fields["name"]); len(errs) > 0 {
+		return nil, fmt.Errorf("invalid name %q: %s", fields["name"], strings.Join(errs, ", "))
+	}
+
+	return &metav1.OwnerReference{
+		APIVersion: fields["apiVersion"],
+		Kind:       fields["kind"],
+		Name:       fields["name"],
+		UID:        types.UID(fields["uid"]),
+	}, nil
+}
+
+// loadAllowedUsers parses the --only-users value, either a path to a file holding one id per line
+// or a comma separated list of ids. An empty value returns an empty set
+func loadAllowedUsers(onlyUsers string) (map[string]bool, error) {
+	allowed := make(map[string]bool)
+	if onlyUsers == "" {
+		return allowed, nil
+	}
+
+	var ids []string
+	if _, err := os.Stat(onlyUsers); err == nil {
+		data, err := os.ReadFile(onlyUsers)
+		if err != nil {
+			return nil, err
+		}
+		ids = strings.Split(string(data), "\n")
+	} else {
+		ids = strings.Split(onlyUsers, ",")
+	}
+
+	for _, id := range ids {
+		id = strings.TrimSpace(id)
+		if id == "" || strings.HasPrefix(id, "#") {
+			continue
+		}
+		allowed[id] = true
+	}
+
+	return allowed, nil
+}
+
+// compileRoleFilter compiles the --role-filter value into a matcher, a "regex:" prefix selects a
+// regular expression, anything else is a glob. An empty filter returns a nil matcher matching all roles
+func compileRoleFilter(filter string) (func(string) bool, error) {
+	if filter == "" {
+		return nil, nil
+	}
+
+	if expr, ok := strings.CutPrefix(filter, "regex:"); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return nil, err
+		}
+		return re.MatchString, nil
+	}
+
+	if _, err := path.Match(filter, ""); err != nil {
+		return nil, err
+	}
+
+	return func(role string) bool {
+		matched, _ := path.Match(filter, role)
+		return matched
+	}, nil
+}
+
+// isAllowedUser reports whether the kubesaw name or its sso id is in the --only-users list, every user is allowed when the list is empty
+func (o *MigrateOptions) isAllowedUser(name string, id string) bool {
+	return len(o.allowedUsers) == 0 || o.allowedUsers[name] || o.allowedUsers[id]
+}
+
+// isExcludedSubject reports whether name matches one of the --exclude-subject-regex patterns
+func (o *MigrateOptions) isExcludedSubject(name string) bool {
+	return slices.ContainsFunc(o.excludeSubjects, func(re *regexp.Regexp) bool {
+		return re.MatchString(name)
+	})
+}
+
+// keptAnnotations returns the annotations whose key starts with one of the --keep-annotation-prefix values,
+// nil when there is none
+func (o *MigrateOptions) keptAnnotations(annotations map[string]string) map[string]string {
+	var kept map[string]string
+	for key, value := range annotations {
+		if key == sourceAnnotation || key == splitAnnotation {
+			continue
+		}
+		if !slices.ContainsFunc(o.KeepAnnotationPrefixes, func(prefix string) bool { return strings.HasPrefix(key, prefix) }) {
+			continue
+		}
+		if kept == nil {
+			kept = make(map[string]string)
+		}
+		kept[key] = value
+	}
+	return kept
+}
+
+// isSampled reports whether the RoleBinding is in the --sample-percent share selected by --sample-seed. The
+// selection hashes the seed with the RoleBinding so it is the same across runs and processing orders
+func (o *MigrateOptions) isSampled(namespace string, name string) bool {
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%d/%s/%s", o.SampleSeed, namespace, name)
+	return float64(h.Sum64()%10000) < o.SamplePercent*100
+}
+
+// isSelectedNamespace reports whether ns was selected via --namespace, any namespace is selected when the flag is not set
+func (o *MigrateOptions) isSelectedNamespace(ns string) bool {
+	return len(o.Namespaces) == 0 || slices.Contains(o.Namespaces, ns)
+}
+
+// getTenantRoleBindings lists the Tenant RoleBindings selected for migration in the Tenant Namespaces nsList,
+// cluster-wide or in the Namespace of a single --namespace, or with --ns-concurrency above 1 in each of
+// nsList from parallel workers. Both ways select the same RoleBindings, only the number of List calls differs.
+// The RoleBindings of earlier runs lack the Tenant labels and are listed by their own label, to be reported
+// as already migrated
+func getTenantRoleBindings(clientset kubernetes.Interface, nsList []string, opts *MigrateOptions, ctx context.Context) ([]rbacv1.RoleBinding, error) {
+	//Get RoleBindings
+	labelSelectors := []string{opts.roleBindingSelector(), migratedRoleBindingSelector}
+
+	opts.logInfo("Gathering information for Tenant Namespaces")
+
+	if opts.NSConcurrency > 1 {
+		nsRoleBindings := make([][]rbacv1.RoleBinding, len(nsList))
+		err := forEachNamespace(nsList, opts.NSConcurrency, func(i int, namespace string) error {
+			for _, labelSelector := range labelSelectors {
+				var rbs *rbacv1.RoleBindingList
+				err := listWithRetry(fmt.Sprintf("RoleBindings of Namespace %s", namespace), opts, ctx, func() (err error) {
+					rbs, err = clientset.RbacV1().RoleBindings(namespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+					return err
+				})
+				if err != nil {
+					return fmt.Errorf("failed to list RoleBindings of Namespace %s: %w", namespace, err)
+				}
+				nsRoleBindings[i] = append(nsRoleBindings[i], rbs.Items...)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, interruptedOr(ctx, err)
+		}
+
+		return opts.selectRoleBindings(uniqueRoleBindings(slices.Concat(nsRoleBindings...))), nil
+	}
+
+	//A single --namespace is listed server-side, sparing the API server the cluster-wide list
+	listNamespace := metav1.NamespaceAll
+	if len(opts.Namespaces) == 1 {
+		listNamespace = opts.Namespaces[0]
+	}
+
+	var listed []rbacv1.RoleBinding
+	for _, labelSelector := range labelSelectors {
+		var rbs *rbacv1.RoleBindingList
+		err := listWithRetry("Tenant RoleBindings", opts, ctx, func() (err error) {
+			rbs, err = clientset.RbacV1().RoleBindings(listNamespace).List(ctx, metav1.ListOptions{LabelSelector: labelSelector})
+			return err
+		})
+		if err != nil {
+			return nil, interruptedOr(ctx, fmt.Errorf("failed to list Tenant RoleBindings: %w", err))
+		}
+		listed = append(listed, rbs.Items...)
+	}
+
+	return opts.selectRoleBindings(inNamespaces(uniqueRoleBindings(listed), nsList)), nil
+}
+
+// uniqueRoleBindings drops the RoleBindings of rbs listed a second time, a migrated RoleBinding may still
+// carry the Tenant labels
+func uniqueRoleBindings(rbs []rbacv1.RoleBinding) []rbacv1.RoleBinding {
+	seen := make(map[string]bool, len(rbs))
+	unique := make([]rbacv1.RoleBinding, 0, len(rbs))
+	for _, rb := range rbs {
+		key := rb.Namespace + "/" + rb.Name
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		unique = append(unique, rb)
+	}
+
+	return unique
+}
+
+// inNamespaces keeps the RoleBindings of rbs in the Namespaces nsList, so a cluster-wide list leaves out the
+// labelled RoleBindings outside the Tenant Namespaces just as the per-namespace lists do
+func inNamespaces(rbs []rbacv1.RoleBinding, nsList []string) []rbacv1.RoleBinding {
+	namespaces := make(map[string]bool, len(nsList))
+	for _, namespace := range nsList {
+		namespaces[namespace] = true
+	}
+
+	kept := make([]rbacv1.RoleBinding, 0, len(rbs))
+	for _, rb := range rbs {
+		if namespaces[rb.Namespace] {
+			kept = append(kept, rb)
+		}
+	}
+
+	return kept
+}
+
+// tenantRoleBindingSelector is the label selector of the RoleBindings kubesaw created in the Tenant Namespaces
+const tenantRoleBindingSelector = "toolchain.dev.openshift.com/provider=codeready-toolchain"
+
+// managedByLabel marks the RoleBindings written by the migration
+const (
+	managedByLabel = "app.kubernetes.io/managed-by"
+	managedBy      = "rbac-migration"
+)
+
+// migratedRoleBindingSelector is the label selector of the RoleBindings written by the migration
+const migratedRoleBindingSelector = managedByLabel + "=" + managedBy
+
+// roleBindingSelector returns the label selector listing the Tenant RoleBindings, the comma of a label
+// selector ANDs --extra-rolebinding-selector with the Tenant one
+func (o *MigrateOptions) roleBindingSelector() string {
+	if o.ExtraSelector == "" {
+		return tenantRoleBindingSelector
+	}
+	return tenantRoleBindingSelector + "," + o.ExtraSelector
+}
+
+// splitSubjects replaces every RoleBinding with several subjects by one RoleBinding per subject, named after
+// the original without the trailing subject names and with the subject name appended, so that --name-template
+// maps each one to its id. A split name taken by another RoleBinding of the Namespace fails the run rather than
+// overwriting it. The split RoleBindings are marked so --prune keeps their source, one of them may bear its name.
+// With --strict-single-subject a multi-subject RoleBinding fails the run instead
+func (o *MigrateOptions) splitSubjects(rbList []rbacv1.RoleBinding) ([]rbacv1.RoleBinding, error) {
+	split := make([]rbacv1.RoleBinding, 0, len(rbList))
+
+	//Every name in use maps to the source RoleBinding holding or producing it
+	taken := make(map[string]string, len(rbList))
+	for _, rb := range rbList {
+		if len(rb.Subjects) <= 1 {
+			taken[rb.Namespace+"/"+rb.Name] = rb.Name
+		}
+	}
+
+	for _, rb := range rbList {
+		if len(rb.Subjects) <= 1 {
+			split = append(split, rb)
+			continue
+		}
+		if o.StrictSingleSubject {
+			return nil, fmt.Errorf("RoleBinding %s in Namespace %s has %d subjects, rejected by --strict-single-subject", rb.Name, rb.Namespace, len(rb.Subjects))
+		}
+
+		o.logInfo(fmt.Sprintf("Splitting RoleBinding %s in Namespace %s into %d single-subject RoleBindings", rb.Name, rb.Namespace, len(rb.Subjects)), "namespace", rb.Namespace, "name", rb.Name, "subjects", len(rb.Subjects))
+		base := splitBaseName(&rb)
+		for _, subject := range rb.Subjects {
+			srb := deriveRoleBinding(&rb)
+			srb.Annotations[splitAnnotation] = "true"
+			srb.Name = fmt.Sprintf("%s-%s", base, subject.Name)
+			if holder, ok := taken[rb.Namespace+"/"+srb.Name]; ok {
+				return nil, fmt.Errorf("RoleBinding %s in Namespace %s splits into %s for %s %s, a name already taken by RoleBinding %s", rb.Name, rb.Namespace, srb.Name, subject.Kind, subject.Name, holder)
+			}
+			taken[rb.Namespace+"/"+srb.Name] = rb.Name
+			srb.Subjects = []rbacv1.Subject{subject}
+			split = append(split, *srb)
+		}
+	}
+
+	return split, nil
+}
+
+// splitBaseName returns the name of rb stripped of the subject names it ends with, such as the user of the
+// kubesaw appstudio-<role>-<user> names, so no split RoleBinding carries the name of another subject
+func splitBaseName(rb *rbacv1.RoleBinding) string {
+	base := rb.Name
+	for trimmed := true; trimmed; {
+		trimmed = false
+		for _, subject := range rb.Subjects {
+			if name, ok := strings.CutSuffix(base, "-"+subject.Name); ok && name != "" {
+				base = name
+				trimmed = true
+			}
+		}
+	}
+
+	return base
+}
+
+// sourceAnnotation holds the name of the source RoleBinding on the RoleBindings split or expanded from it, so
+// --prune can tell whether all of them were migrated. It is dropped when migrating
+const sourceAnnotation = "rbac-migration.konflux-ci.dev/source"
+
+// splitAnnotation marks the RoleBindings split from a multi-subject source, which --prune leaves in place
+const splitAnnotation = "rbac-migration.konflux-ci.dev/split"
+
+// deriveRoleBinding returns a copy of rb annotated with the name of its source RoleBinding
+func deriveRoleBinding(rb *rbacv1.RoleBinding) *rbacv1.RoleBinding {
+	derived := rb.DeepCopy()
+	if derived.Annotations == nil {
+		derived.Annotations = make(map[string]string)
+	}
+	derived.Annotations[sourceAnnotation] = sourceName(rb)
+	return derived
+}
+
+// sourceName returns the name of the source RoleBinding rb was split or expanded from, its own name otherwise
+func sourceName(rb *rbacv1.RoleBinding) string {
+	if name, ok := rb.Annotations[sourceAnnotation]; ok {
+		return name
+	}
+	return rb.Name
+}
+
+// recordDiffOrigins keeps a copy of the source RoleBindings rbList with --diff, before they are split or expanded
+func (o *MigrateOptions) recordDiffOrigins(rbList []rbacv1.RoleBinding) {
+	if !o.Diff {
+		return
+	}
+	o.diffOrigins = make(map[string]*rbacv1.RoleBinding, len(rbList))
+	for i := range rbList {
+		o.diffOrigins[rbList[i].Namespace+"/"+rbList[i].Name] = rbList[i].DeepCopy()
+	}
+}
+
+// diffSource returns the RoleBinding rb is diffed against: the source it was split or expanded from, or a copy
+// of rb without the annotations of the split when that source was not recorded. The mutation writes through to
+// the shared subjects, so the copy is taken first
+func (o *MigrateOptions) diffSource(rb *rbacv1.RoleBinding) *rbacv1.RoleBinding {
+	if origin, ok := o.diffOrigins[rb.Namespace+"/"+sourceName(rb)]; ok {
+		return origin
+	}
+
+	source := rb.DeepCopy()
+	delete(source.Annotations, sourceAnnotation)
+	delete(source.Annotations, splitAnnotation)
+	return source
+}
+
+// describeSelection returns the source and filters the RoleBindings were selected with, for the messages
+// of an empty selection
+func (o *MigrateOptions) describeSelection() string {
+	selection := []string{fmt.Sprintf("label selector %s", o.roleBindingSelector())}
+	if o.InputFile != "" {
+		selection = []string{fmt.Sprintf("--input-file %s", o.InputFile)}
+	}
+	if o.Source == "spacebinding" {
+		selection = []string{fmt.Sprintf("the space bindings of %s namespace", o.SpaceBindingNamespace)}
+	}
+	if len(o.Namespaces) > 0 {
+		selection = append(selection, fmt.Sprintf("--namespace %s", strings.Join(o.Namespaces, ",")))
+	}
+	if o.RoleFilter != "" {
+		selection = append(selection, fmt.Sprintf("--role-filter %s", o.RoleFilter))
+	}
+	if o.CreatedAfter != "" {
+		selection = append(selection, fmt.Sprintf("--created-after %s", o.CreatedAfter))
+	}
+	return strings.Join(selection, ", ")
+}
+
+// selectRoleBindings returns the RoleBindings of rbs selected for migration by the namespace, role and creation time filters
+func (o *MigrateOptions) selectRoleBindings(rbs []rbacv1.RoleBinding) []rbacv1.RoleBinding {
+	rbList := make([]rbacv1.RoleBinding, 0, len(rbs))
+
+	for _, rb := range rbs {
+		rbName := rb.Name
+		if rbName == "appstudio-pipelines-runner-rolebinding" {
+			continue
+		}
+		if !o.isSelectedNamespace(rb.Namespace) {
+			continue
+		}
+		if o.roleMatcher != nil && !o.roleMatcher(rb.RoleRef.Name) {
+			continue
+		}
+		//Reading the live timestamp, it is only wiped later in the mutate stage
+		if !o.createdAfter.IsZero() && rb.CreationTimestamp.Time.Before(o.createdAfter) {
+			continue
+		}
+		rbList = append(rbList, rb)
+	}
+
+	return rbList
+}
+
+// mutateTenantRoleBindings migrates rbList to the ids of idMap, setting in result the migrated RoleBindings,
+// their mappings, the per-namespace breakdown and the Tenant Namespaces left without any migrated RoleBinding.
+// Namespaces are processed by --ns-concurrency workers and their results are merged in Namespace order
+func mutateTenantRoleBindings(idMap map[string]string, nsList []string, rbList []rbacv1.RoleBinding, opts *MigrateOptions, result *Result) error {
+	stats := &result.Stats
+	processedNamespaces := make(map[string]*NamespaceSummary)
+	nsRoleBindings := make(map[string][]rbacv1.RoleBinding)
+	ssoIDs := make(map[string]bool, len(idMap))
+	for _, id := range idMap {
+		ssoIDs[id] = true
+	}
+
+	//Tenant Namespaces without any source RoleBinding are orphans as well
+	for _, namespace := range nsList {
+		processedNamespaces[namespace] = &NamespaceSummary{Namespace: namespace}
+	}
+	for _, rb := range rbList {
+		if _, exists := processedNamespaces[rb.Namespace]; !exists {
+			processedNamespaces[rb.Namespace] = &NamespaceSummary{Namespace: rb.Namespace}
+		}
+		nsRoleBindings[rb.Namespace] = append(nsRoleBindings[rb.Namespace], rb)
+	}
+
+	var progressMu sync.Mutex
+	processed := 0
+	progress := func() {
+		progressMu.Lock()
+		defer progressMu.Unlock()
+		processed++
+		opts.reportProgress("Processing RoleBindings", processed, len(rbList))
+	}
+
+	//Each worker only touches the summary and the result slot of its own Namespace
+	namespaces := slices.Sorted(maps.Keys(processedNamespaces))
+	nsMigrated := make([][]rbacv1.RoleBinding, len(namespaces))
+	nsMappings := make([][]RoleBindingMapping, len(namespaces))
+	nsDiffs := make([][]RoleBindingDiff, len(namespaces))
+	err := forEachNamespace(namespaces, opts.NSConcurrency, func(i int, namespace string) error {
+		var err error
+		nsMigrated[i], nsMappings[i], nsDiffs[i], err = mutateNamespaceRoleBindings(idMap, ssoIDs, nsRoleBindings[namespace], opts, processedNamespaces[namespace], progress)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	mrbList := slices.Concat(nsMigrated...)
+	mappings := slices.Concat(nsMappings...)
+	diffs := slices.Concat(nsDiffs...)
+	for _, diff := range diffs {
+		stats.DiffLinesAdded += diff.Added
+		stats.DiffLinesRemoved += diff.Removed
+	}
+
+	if opts.SamplePercent < 100 {
+		notSampled := 0
+		for _, nsSummary := range processedNamespaces {
+			notSampled += nsSummary.Skipped[SkipNotSampled]
+		}
+		opts.logInfo(fmt.Sprintf("Sampled %d of %d RoleBindings with --sample-percent %g and --sample-seed %d", len(rbList)-notSampled, len(rbList), opts.SamplePercent, opts.SampleSeed),
+			"sampled", len(rbList)-notSampled, "total", len(rbList), "percent", opts.SamplePercent, "seed", opts.SampleSeed)
+	}
+
+	var orphans []string
+	opts.logInfo("Searching for post-migration orphan Tenant Namespaces:")
+	summaries := make([]NamespaceSummary, 0, len(processedNamespaces))
+	for _, ns := range namespaces {
+		nsSummary := processedNamespaces[ns]
+		stats.AlreadyMigrated += nsSummary.AlreadyMigrated
+		if nsSummary.Migrated+nsSummary.AlreadyMigrated == 0 {
+			opts.logWarn(ns, "namespace", ns, "orphan", true)
+			orphans = append(orphans, ns)
+		}
+		summaries = append(summaries, *nsSummary)
+	}
+
+	if len(orphans) == 0 {
+		opts.logInfo("No orphan Tenant Namespaces found")
+	} else {
+		opts.logWarn(fmt.Sprintf("There were %d orphan Tenant Namespaces found", len(orphans)), "count", len(orphans))
+	}
+
+	stats.RoleBindingsMutated = len(mrbList)
+	stats.RoleBindingsSkipped = len(rbList) - len(mrbList) - stats.AlreadyMigrated
+	stats.OrphanNamespaces = len(orphans)
+
+	result.RoleBindings = mrbList
+	result.Mappings = mappings
+	result.Diffs = diffs
+	result.OrphanNamespaces = orphans
+	result.Namespaces = summaries
+
+	return nil
+}
+
+// mutateNamespaceRoleBindings migrates the RoleBindings rbs of a single Namespace to the ids of idMap,
+// tallying them in nsSummary and calling progress for each one. With --diff it also returns the diff of each one
+func mutateNamespaceRoleBindings(idMap map[string]string, ssoIDs map[string]bool, rbs []rbacv1.RoleBinding, opts *MigrateOptions, nsSummary *NamespaceSummary, progress func()) ([]rbacv1.RoleBinding, []RoleBindingMapping, []RoleBindingDiff, error) {
+	mrbList := make([]rbacv1.RoleBinding, 0, len(rbs))
+	mappings := make([]RoleBindingMapping, 0, len(rbs))
+	var diffs []RoleBindingDiff
+	processedRBs := make(map[string]int)
+	//Split and expanded RoleBindings share their source, which is counted once
+	origins := make(map[string]bool)
+
+	for _, rb := range rbs {
+		progress()
+		var source *rbacv1.RoleBinding
+		if opts.Diff {
+			source = opts.diffSource(&rb)
+		}
+		namespace := rb.Namespace
+		origin := sourceName(&rb)
+		_, derived := rb.Annotations[sourceAnnotation]
+		if !origins[origin] {
+			origins[origin] = true
+			nsSummary.Source++
+		}
+
+		rbName := rb.Name
+		if opts.SamplePercent < 100 {
+			if !opts.isSampled(namespace, rbName) {
+				nsSummary.skip(SkipNotSampled)
+				continue
+			}
+			opts.logInfo(fmt.Sprintf("Sampled RoleBinding %s in Namespace %s", rbName, namespace), "namespace", namespace, "name", rbName, "sampled", true)
+		}
+		//Nothing to migrate, and no subject to read the kind of
+		if len(rb.Subjects) == 0 {
+			opts.logWarn(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s without subjects", rbName, namespace), "namespace", namespace, "name", rbName)
+			nsSummary.skip(SkipNoSubject)
+			continue
+		}
+		subject := rb.Subjects[0]
+		role := rb.RoleRef.Name
+
+		if _, empty := rb.Annotations[emptyGroupAnnotation]; empty {
+			opts.logWarn(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, Group %s has no users to expand", rbName, namespace, subject.Name), "namespace", namespace, "name", rbName, "group", subject.Name)
+			nsSummary.skip(SkipEmptyGroup)
+			continue
+		}
+
+		if isMigratedRoleBinding(rb, opts) {
+			opts.logInfo(fmt.Sprintf("RoleBinding %s in Namespace %s is already migrated", rbName, namespace), "namespace", namespace, "name", rbName, "subject", subject.Name)
+			nsSummary.AlreadyMigrated++
+			continue
+		}
+
+		if opts.isExcludedSubject(subject.Name) {
+			opts.logInfo(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, subject %s matches --exclude-subject-regex", rbName, namespace, subject.Name), "namespace", namespace, "name", rbName, "subject", subject.Name)
+			nsSummary.skip(SkipExcluded)
+			continue
+		}
+
+		//Only bindings to roles are understood, anything else would be rewritten into a ClusterRole binding
+		if rb.RoleRef.Kind != "Role" && rb.RoleRef.Kind != "ClusterRole" {
+			opts.logWarn(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, roleRef kind %q is not Role or ClusterRole", rbName, namespace, rb.RoleRef.Kind), "namespace", namespace, "name", rbName, "kind", rb.RoleRef.Kind)
+			nsSummary.skip(SkipUnexpectedRoleRef)
+			continue
+		}
+
+		cRole := opts.transformRole(role)
+		roleKind := "ClusterRole"
+		if target, ok := opts.roleMap.lookup(namespace, role); ok {
+			cRole, roleKind = target.Name, target.Kind
+		} else if rb.RoleRef.Kind == "ClusterRole" && !opts.roleTransform.MatchString(role) {
+			//Nothing to remap, the binding is likely mis-targeted
+			if opts.UnmappedRoles == "skip" {
+				opts.logWarn(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, ClusterRole %s does not match --role-transform and has no --role-map entry", rbName, namespace, role), "namespace", namespace, "name", rbName, "role", role)
+				nsSummary.skip(SkipUnmappedRole)
+				continue
+			}
+			opts.logWarn(fmt.Sprintf("RoleBinding %s in Namespace %s references ClusterRole %s, which does not match --role-transform and has no --role-map entry", rbName, namespace, role), "namespace", namespace, "name", rbName, "role", role)
+		}
+		id := subject.Name
+
+		switch subject.Kind {
+		case rbacv1.UserKind:
+			var exists bool
+			id, exists = opts.lookupID(idMap, subject.Name)
+			if !exists && ssoIDs[subject.Name] {
+				//Subject is already an sso id from a previous partial run
+				opts.logInfo(fmt.Sprintf("RoleBinding %s in Namespace %s already has sso subject %s", rbName, namespace, subject.Name), "namespace", namespace, "name", rbName, "subject", subject.Name)
+				nsSummary.AlreadyMigrated++
+				continue
+			}
+			if !exists {
+				// Not adding new RoleBindings for accounts not found in corporate ldap
+				opts.logInfo(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, account %s was not resolved", rbName, namespace, subject.Name), "namespace", namespace, "name", rbName, "account", subject.Name)
+				nsSummary.skip(SkipUnresolved)
+				continue
+			}
+			if !opts.isAllowedUser(subject.Name, id) {
+				opts.logInfo(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, user %s is not in the --only-users list", rbName, namespace, id), "namespace", namespace, "name", rbName, "account", subject.Name)
+				nsSummary.skip(SkipNotAllowed)
+				continue
+			}
+			rb.Subjects[0].Name = id
+			rb.Subjects[0].Kind = opts.SubjectKind
+			rb.Subjects[0].APIGroup = opts.SubjectAPIGroup
+			rb.Subjects[0].Namespace = ""
+		case rbacv1.GroupKind, rbacv1.ServiceAccountKind:
+			// Groups and ServiceAccounts are not kubesaw users, so there is nothing to remap
+			if opts.NonUserSubjects == "skip" {
+				opts.logInfo(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s with %s subject %s", rbName, namespace, subject.Kind, subject.Name), "namespace", namespace, "name", rbName, "subject", subject.Name)
+				nsSummary.skip(SkipNonUserSubject)
+				continue
+			}
+		default:
+			opts.logWarn(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s with unknown subject kind %s", rbName, namespace, subject.Kind), "namespace", namespace, "name", rbName, "subject", subject.Name)
+			nsSummary.skip(SkipUnknownKind)
+			continue
+		}
+
+		nrbName, err := opts.renderName(nameTemplateData{
+			Name:       rbName,
+			Namespace:  namespace,
+			Subject:    subject.Name,
+			Id:         id,
+			Role:       cRole,
+			SourceRole: role,
+		})
+		if err != nil {
+			opts.logError(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, failed to render --name-template: %v", rbName, namespace, err), "namespace", namespace, "name", rbName, "error", err)
+			nsSummary.skip(SkipInvalidName)
+			continue
+		}
+		validName, err := toDNS1123Name(nrbName)
+		if err != nil {
+			opts.logError(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, migrated name %s is invalid: %v", rbName, namespace, nrbName, err), "namespace", namespace, "name", rbName, "account", subject.Name)
+			nsSummary.skip(SkipInvalidName)
+			continue
+		}
+		if validName != nrbName {
+			opts.logWarn(fmt.Sprintf("Migrated RoleBinding name %s in Namespace %s is not DNS-1123 compliant, using %s", nrbName, namespace, validName), "namespace", namespace, "name", validName, "account", subject.Name)
+		}
+		rb.Name = validName
+		rb.RoleRef.Kind = roleKind
+		rb.RoleRef.Name = cRole
+		//Cleaning metadata
+		rb.ObjectMeta.Annotations = opts.keptAnnotations(rb.ObjectMeta.Annotations)
+		rb.ObjectMeta.Labels = map[string]string{"konflux-ci.dev/type": "user", managedByLabel: managedBy}
+		rb.ObjectMeta.ResourceVersion = ""
+		rb.ObjectMeta.UID = ""
+		rb.ObjectMeta.CreationTimestamp = metav1.Time{}
+		rb.ObjectMeta.ManagedFields = nil
+		if opts.ownerRef != nil {
+			rb.ObjectMeta.OwnerReferences = []metav1.OwnerReference{*opts.ownerRef}
+		}
+		rb.APIVersion = "rbac.authorization.k8s.io/v1"
+		rb.Kind = "RoleBinding"
+		//Source RoleBindings read from a file may omit the roleRef apiGroup the API server defaults
+		if rb.RoleRef.APIGroup == "" {
+			rb.RoleRef.APIGroup = rbacv1.GroupName
+		}
+
+		if err := validateRoleBinding(&rb); err != nil {
+			opts.logError(fmt.Sprintf("Skipping RoleBinding %s in Namespace %s, migrated RoleBinding %s is invalid: %v", rbName, namespace, rb.Name, err), "namespace", namespace, "name", rbName, "error", err)
+			nsSummary.skip(SkipInvalidRoleBinding)
+			continue
+		}
+
+		//Skip if RB already processed to avoid duplicates
+		processedRB := fmt.Sprintf("(%s-%s)", rb.Namespace, rb.Name)
+
+		if _, exists := processedRBs[processedRB]; exists {
+			opts.logInfo(fmt.Sprintf("RoleBinding %s for Namespace %s was already processed, collapsing duplicate from %s", rb.Name, rb.Namespace, rbName), "namespace", rb.Namespace, "name", rb.Name)
+			nsSummary.skip(SkipDuplicate)
+			continue
+		}
+
+		processedRBs[processedRB] = 1
+		nsSummary.Migrated++
+		mrbList = append(mrbList, rb)
+		mapping := RoleBindingMapping{
+			OldNamespace: namespace,
+			OldName:      origin,
+			OldSubject:   subject.Name,
+			OldRole:      role,
+			NewName:      rb.Name,
+			NewSubject:   rb.Subjects[0].Name,
+			NewRole:      rb.RoleRef.Name,
+		}
+		if derived {
+			mapping.DerivedName = rbName
+		}
+		mappings = append(mappings, mapping)
+
+		if opts.Diff {
+			diff, err := diffRoleBindings(source, &rb)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("failed to diff RoleBinding %s in Namespace %s: %w", rbName, namespace, err)
+			}
+			diffs = append(diffs, diff)
+		}
+	}
+
+	return mrbList, mappings, diffs, nil
+}
+
+// writeMigratedRoleBindings writes rbList to --output-file in the selected --output-format, returning the number written
+func writeMigratedRoleBindings(rbList []rbacv1.RoleBinding, opts *MigrateOptions) (int, error) {
+	file := os.Stdout
+	if opts.OutputFile != "-" {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if opts.Append {
+			flags = os.O_WRONLY | os.O_CREATE | os.O_APPEND
+		}
+
+		var err error
+		file, err = os.OpenFile(opts.OutputFile, flags, 0666)
+		if err != nil {
+			return 0, fmt.Errorf("failed to create file: %w", err)
+		}
+
+		defer file.Close()
+	}
+
+	//Appended documents go after the header written by the first wave
+	appending := false
+	if fi, err := file.Stat(); err == nil && opts.Append && fi.Size() > 0 {
+		appending = true
+	}
+
+	written, err := renderRoleBindings(file, rbList, opts, !appending)
+	if err != nil {
+		return written, err
+	}
+
+	opts.logInfo(fmt.Sprintf("Wrote %d migrated RoleBindings to %s", written, opts.OutputFile), "count", written, "file", opts.OutputFile)
+
+	return written, nil
+}
+
+// roleOutputFile returns the --output-by-role file name of rb, named after its target role. Roles are
+// prefixed so they cannot clash with a ClusterRole of the same name
+func roleOutputFile(rb *rbacv1.RoleBinding, opts *MigrateOptions) string {
+	name := rb.RoleRef.Name
+	if rb.RoleRef.Kind == "Role" {
+		name = "role-" + name
+	}
+	return name + "." + outputFormats[opts.OutputFormat].ext
+}
+
+// writeRoleBindingsByRole writes rbList to one file per target role in --output-dir, each file sorted
+// like rbList. Existing files are checked before writing any, returning the number of RoleBindings written
+func writeRoleBindingsByRole(rbList []rbacv1.RoleBinding, opts *MigrateOptions) (int, error) {
+	byFile := make(map[string][]rbacv1.RoleBinding)
+	for _, rb := range rbList {
+		file := roleOutputFile(&rb, opts)
+		byFile[file] = append(byFile[file], rb)
+	}
+	files := slices.Sorted(maps.Keys(byFile))
+
+	if err := os.MkdirAll(opts.OutputDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create --output-dir: %w", err)
+	}
+	if !opts.Force && !opts.Append {
+		for _, file := range files {
+			if _, err := os.Stat(filepath.Join(opts.OutputDir, file)); err == nil {
+				return 0, fmt.Errorf("refusing to overwrite existing %s in --output-dir, pass --force to overwrite it or --append to add to it", file)
+			}
+		}
+	}
+
+	total := 0
+	for _, file := range files {
+		fileOpts := *opts
+		fileOpts.OutputFile = filepath.Join(opts.OutputDir, file)
+		written, err := writeMigratedRoleBindings(byFile[file], &fileOpts)
+		total += written
+		if err != nil {
+			return total, err
+		}
+	}
+
+	return total, nil
+}
+
+// uploadMigratedRoleBindings renders rbList in the selected --output-format and stores it at --output-url
+// through the sink of its scheme, returning the number of RoleBindings uploaded
+func uploadMigratedRoleBindings(rbList []rbacv1.RoleBinding, opts *MigrateOptions, ctx context.Context) (int, error) {
+	var buf bytes.Buffer
+	written, err := renderRoleBindings(&buf, rbList, opts, true)
+	if err != nil {
+		return written, err
+	}
+
+	contentType := outputFormats[opts.OutputFormat].contentType
+	if err := outputSinks[opts.outputURL.Scheme](opts.outputURL, buf.Bytes(), contentType, ctx); err != nil {
+		return 0, interruptedOr(ctx, fmt.Errorf("failed to upload to %s: %w", displayURL(opts.outputURL), err))
+	}
+
+	opts.logInfo(fmt.Sprintf("Uploaded %d migrated RoleBindings (%d bytes) to %s", written, buf.Len(), displayURL(opts.outputURL)), "count", written, "bytes", buf.Len(), "url", displayURL(opts.outputURL))
+
+	return written, nil
+}
+
+// renderRoleBindings writes rbList to w in the selected --output-format, preceded by the format header
+// when withHeader is set. RoleBindings failing to render are logged and skipped, it returns the number written
+func renderRoleBindings(w io.Writer, rbList []rbacv1.RoleBinding, opts *MigrateOptions, withHeader bool) (int, error) {
+	written := 0
+
+	format := outputFormats[opts.OutputFormat]
+	header := ""
+	if withHeader {
+		header = outputHeader(format.header, format, opts)
+	}
+
+	//The header goes with the first RoleBinding, so that a format can replace it when none is rendered
+	headerWritten := false
+	for _, rb := range rbList {
+		//writing RoleBinding
+		data, err := format.render(&rb, opts)
+		if err != nil {
+			opts.logError(fmt.Sprintf("Failed to encode RoleBinding %s to %s: %v", rb.Name, opts.OutputFormat, err), "namespace", rb.Namespace, "name", rb.Name, "error", err)
+			continue
+		}
+		if len(data) == 0 {
+			continue
+		}
+
+		if !headerWritten {
+			if _, err := io.WriteString(w, header); err != nil {
+				return 0, fmt.Errorf("failed to write header: %w", err)
+			}
+			headerWritten = true
+		}
+
+		_, err = w.Write(data)
+		if err != nil {
+			opts.logError(fmt.Sprintf("Failed to write RoleBinding %s to file: %v", rb.Name, err), "namespace", rb.Namespace, "name", rb.Name, "error", err)
+			continue
+		}
+
+		written++
+	}
+
+	if !headerWritten {
+		if withHeader && format.empty != nil {
+			header = outputHeader(format.empty, format, opts)
+		}
+		if _, err := io.WriteString(w, header); err != nil {
+			return 0, fmt.Errorf("failed to write header: %w", err)
+		}
+	}
+
+	return written, nil
+}
+
+// outputHeader returns the header of format, preceded by the provenance comment block with --output-header
+func outputHeader(header func(opts *MigrateOptions) string, format outputFormat, opts *MigrateOptions) string {
+	text := ""
+	if header != nil {
+		text = header(opts)
+	}
+	if opts.OutputHeader && format.comments {
+		//The provenance goes after the shebang of a script, before anything else otherwise
+		shebang := ""
+		if strings.HasPrefix(text, "#!") {
+			shebang, text, _ = strings.Cut(text, "\n")
+			shebang += "\n"
+		}
+		text = shebang + provenanceHeader(opts) + text
+	}
+	return text
+}
+
+// DefaultNameTemplate reproduces the historical naming, swapping appstudio for konflux and the kubesaw name for the sso id
+const DefaultNameTemplate = `{{ .Name | replace "appstudio" "konflux" | replace .Subject .Id }}`
+
+// nameTemplateData holds the variables available to --name-template
+type nameTemplateData struct {
+	Name       string // source RoleBinding name
+	Namespace  string
+	Subject    string // source subject name
+	Id         string // migrated subject name
+	Role       string // migrated role name
+	SourceRole string
+}
+
+// nameTemplateFuncs returns the functions of --name-template, replace swaps every old when ReplaceAll is set
+func (o *MigrateOptions) nameTemplateFuncs() template.FuncMap {
+	count := 1
+	if o.ReplaceAll {
+		count = -1
+	}
+
+	return template.FuncMap{
+		// replace swaps the first old for new in s, argument order allows pipelines
+		"replace": func(old string, new string, s string) string {
+			return strings.Replace(s, old, new, count)
+		},
+		"lower": strings.ToLower,
+	}
+}
+
+// renderName renders the migrated RoleBinding name from --name-template
+func (o *MigrateOptions) renderName(data nameTemplateData) (string, error) {
+	var sb strings.Builder
+	if err := o.nameTemplate.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// isMigratedRoleBinding reports whether rb already has its migrated form: the label of the migration, a
+// ClusterRole or mapped Role reference without a token left to rename and, for users, the sso subject Kind/APIGroup
+func isMigratedRoleBinding(rb rbacv1.RoleBinding, opts *MigrateOptions) bool {
+	if rb.Labels[managedByLabel] != managedBy || len(rb.Subjects) == 0 {
+		return false
+	}
+
+	if (rb.RoleRef.Kind != "ClusterRole" && rb.RoleRef.Kind != "Role") || strings.Contains(rb.RoleRef.Name, "appstudio") || strings.Contains(rb.Name, "appstudio") {
+		return false
+	}
+
+	subject := rb.Subjects[0]
+	switch subject.Kind {
+	case rbacv1.GroupKind, rbacv1.ServiceAccountKind:
+		return true
+	default:
+		return subject.Kind == opts.SubjectKind && subject.APIGroup == opts.SubjectAPIGroup
+	}
+}
+
+// validateRoleBinding checks that the fields the API server requires of rb are populated
+func validateRoleBinding(rb *rbacv1.RoleBinding) error {
+	if rb.Name == "" || rb.Namespace == "" {
+		return fmt.Errorf("name and namespace are required")
+	}
+	if rb.RoleRef.Name == "" {
+		return fmt.Errorf("roleRef name is required")
+	}
+	if rb.RoleRef.Kind != "Role" && rb.RoleRef.Kind != "ClusterRole" {
+		return fmt.Errorf("roleRef kind %q is not Role or ClusterRole", rb.RoleRef.Kind)
+	}
+	if rb.RoleRef.APIGroup != rbacv1.GroupName {
+		return fmt.Errorf("roleRef apiGroup %q is not %s", rb.RoleRef.APIGroup, rbacv1.GroupName)
+	}
+	if len(rb.Subjects) == 0 {
+		return fmt.Errorf("a subject is required")
+	}
+	for _, subject := range rb.Subjects {
+		if subject.Kind == "" || subject.Name == "" {
+			return fmt.Errorf("subject kind and name are required")
+		}
+		if subject.Kind == rbacv1.ServiceAccountKind && subject.Namespace == "" {
+			return fmt.Errorf("ServiceAccount subject %s requires a namespace", subject.Name)
+		}
+	}
+	return nil
+}
+
+// toDNS1123Name returns name if it is a valid DNS-1123 subdomain, otherwise a lowercased form with
+// invalid characters replaced by '-' and truncated to the max length, or an error if that is still invalid
+func toDNS1123Name(name string) (string, error) {
+	if len(validation.IsDNS1123Subdomain(name)) == 0 {
+		return name, nil
+	}
+
+	sanitized := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9', r == '-', r == '.':
+			return r
+		default:
+			return '-'
+		}
+	}, strings.ToLower(name))
+
+	if len(sanitized) > validation.DNS1123SubdomainMaxLength {
+		sanitized = sanitized[:validation.DNS1123SubdomainMaxLength]
+	}
+	sanitized = strings.Trim(sanitized, "-.")
+
+	if errs := validation.IsDNS1123Subdomain(sanitized); len(errs) > 0 {
+		return "", fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return sanitized, nil
+}
+
+// encodeRoleBinding encodes rb to YAML, dropping the null creationTimestamp from the object tree
+// so it never leaks into the output regardless of the serializer layout
+func encodeRoleBinding(rb *rbacv1.RoleBinding) ([]byte, error) {
+	obj, err := roleBindingObject(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	return yaml.Marshal(obj)
+}
+
+// roleBindingObject returns the object tree of rb without the null creationTimestamp
+func roleBindingObject(rb *rbacv1.RoleBinding) (map[string]interface{}, error) {
+	obj, err := runtime.DefaultUnstructuredConverter.ToUnstructured(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	unstructured.RemoveNestedField(obj, "metadata", "creationTimestamp")
+
+	return obj, nil
+}
+
+// sortRoleBindings sorts rbList in place by Namespace then Name
+func sortRoleBindings(rbList []rbacv1.RoleBinding) {
+	slices.SortStableFunc(rbList, func(a, b rbacv1.RoleBinding) int {
+		return cmp.Or(
+			cmp.Compare(a.Namespace, b.Namespace),
+			cmp.Compare(a.Name, b.Name),
+		)
+	})
+}
+
+// migrate migrates the Tenant RoleBindings to the ids of idMap into result, then writes and applies them
+func migrate(idMap map[string]string, config *rest.Config, opts *MigrateOptions, result *Result, ctx context.Context) error {
+	//Init k8s client
+	var clientset kubernetes.Interface
+	if config != nil {
+		var err error
+		clientset, err = kubernetes.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create k8s client: %w", err)
+		}
+	}
+
+	var nsList []string
+	var rbList []rbacv1.RoleBinding
+	if opts.InputFile != "" {
+		rbs, err := loadRoleBindingsFile(opts.InputFile)
+		if err != nil {
+			return fmt.Errorf("failed to load --input-file: %w", err)
+		}
+		rbList = opts.selectRoleBindings(rbs)
+		opts.logInfo(fmt.Sprintf("Loaded %d Tenant RoleBindings from %s", len(rbList), opts.InputFile), "count", len(rbList), "file", opts.InputFile)
+	} else {
+		var err error
+		nsList, err = getTenantNamespaces(clientset, opts, ctx)
+		if err != nil {
+			return err
+		}
+
+		opts.logInfo(fmt.Sprintf("Found %d Tenant Namespaces", len(nsList)), "count", len(nsList))
+
+		if opts.Source == "spacebinding" {
+			hostclient, err := hostDynamicClient(config, opts)
+			if err != nil {
+				return err
+			}
+			rbList, err = getSpaceBindingRoleBindings(hostclient, nsList, opts, ctx)
+			if err != nil {
+				return err
+			}
+		} else {
+			rbList, err = getTenantRoleBindings(clientset, nsList, opts, ctx)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	//An empty selection would write an empty output looking like a successful run
+	if len(rbList) == 0 {
+		if opts.RequireRoleBindings {
+			return fmt.Errorf("no Tenant RoleBindings matched %s", opts.describeSelection())
+		}
+		opts.logWarn(fmt.Sprintf("No Tenant RoleBindings matched %s, nothing will be migrated", opts.describeSelection()), "selection", opts.describeSelection())
+	}
+
+	if opts.MaxRoleBindings > 0 && len(rbList) > opts.MaxRoleBindings && !opts.Force {
+		return fmt.Errorf("found %d Tenant RoleBindings, more than --max-rolebindings %d. Narrow the selection or pass --force to proceed", len(rbList), opts.MaxRoleBindings)
+	}
+
+	opts.recordDiffOrigins(rbList)
+	rbList, err := opts.splitSubjects(rbList)
+	if err != nil {
+		return err
+	}
+
+	if opts.ExpandGroups {
+		dynclient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create k8s client: %w", err)
+		}
+
+		members, err := getGroupMembers(dynclient, rbList, opts, ctx)
+		if err != nil {
+			return err
+		}
+		rbList = expandGroupSubjects(rbList, members)
+	}
+
+	//Counted before the mutation, which skips some of the derived RoleBindings
+	derived := countDerived(rbList)
+
+	if err := mutateTenantRoleBindings(idMap, nsList, rbList, opts, result); err != nil {
+		return err
+	}
+	mrbList, mappings := result.RoleBindings, result.Mappings
+
+	//Sorting by Namespace then Name for stable output across runs
+	sortRoleBindings(mrbList)
+
+	if opts.ValidateRoles != "off" {
+		if err := validateTargetRoles(clientset, mrbList, opts, ctx); err != nil {
+			return err
+		}
+	}
+
+	if opts.ValidateIdentities {
+		dynclient, err := dynamic.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create k8s client: %w", err)
+		}
+		if err := validateIdentities(dynclient, mrbList, opts, ctx); err != nil {
+			return err
+		}
+	}
+
+	//Auditing only needs the migrated RoleBindings of the result
+	if opts.EffectiveBindingsOnly {
+		return nil
+	}
+
+	//The same migrated RoleBindings feed both the file and the apply sinks
+	if opts.outputURL != nil {
+		written, err := uploadMigratedRoleBindings(mrbList, opts, ctx)
+		if err != nil {
+			return err
+		}
+		result.Stats.RoleBindingsWritten = written
+	} else if opts.OutputByRole {
+		written, err := writeRoleBindingsByRole(mrbList, opts)
+		if err != nil {
+			return err
+		}
+		result.Stats.RoleBindingsWritten = written
+	} else if opts.OutputFile != "" {
+		written, err := writeMigratedRoleBindings(mrbList, opts)
+		if err != nil {
+			return err
+		}
+		result.Stats.RoleBindingsWritten = written
+	}
+
+	if opts.OutputConfigMap != "" {
+		written, err := writeMigratedConfigMap(clientset, mrbList, opts, ctx)
+		if err != nil {
+			return err
+		}
+		result.Stats.RoleBindingsWritten = max(result.Stats.RoleBindingsWritten, written)
+	}
+
+	if opts.MappingReport != "" {
+		if err := writeMappingReport(opts.MappingReport, mappings); err != nil {
+			return fmt.Errorf("failed to write mapping report: %w", err)
+		}
+		opts.logInfo(fmt.Sprintf("Wrote %d RoleBinding mappings to %s", len(mappings), opts.MappingReport), "count", len(mappings), "file", opts.MappingReport)
+	}
+
+	if opts.NamespaceReport != "" {
+		if err := writeNamespaceReport(opts.NamespaceReport, result.Namespaces); err != nil {
+			return fmt.Errorf("failed to write namespace report: %w", err)
+		}
+		opts.logInfo(fmt.Sprintf("Wrote %d Namespace summaries to %s", len(result.Namespaces), opts.NamespaceReport), "count", len(result.Namespaces), "file", opts.NamespaceReport)
+	}
+
+	if opts.OrphanReport != "" {
+		orphans := orphanNamespaces(result.Namespaces)
+		if err := writeOrphanReport(opts.OrphanReport, orphans); err != nil {
+			return fmt.Errorf("failed to write orphan report: %w", err)
+		}
+		opts.logInfo(fmt.Sprintf("Wrote %d orphan Tenant Namespaces to %s", len(orphans), opts.OrphanReport), "count", len(orphans), "file", opts.OrphanReport)
+	}
+
+	//With --keep-going the apply failures are returned once the metrics are written
+	var applyErr error
+	if opts.Apply {
+		var failed map[string]bool
+		failed, applyErr = applyRoleBindings(clientset, mrbList, opts, &result.Stats, ctx)
+		if applyErr != nil && !errors.Is(applyErr, ErrApplyFailed) {
+			return applyErr
+		}
+
+		//Sources are only pruned once their migrated RoleBindings exist
+		if opts.Prune {
+			if err := pruneSourceRoleBindings(clientset, mappings, derived, failed, opts, &result.Stats, ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.MetricsFile != "" {
+		if err := result.Stats.WriteMetrics(opts.MetricsFile); err != nil {
+			return fmt.Errorf("failed to write metrics file: %w", err)
+		}
+		opts.logInfo(fmt.Sprintf("Wrote metrics to %s", opts.MetricsFile), "file", opts.MetricsFile)
+	}
+
+	return applyErr
+}
@@ -0,0 +1,782 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"bytes"
+	"context"
+	"maps"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestMutateNonUserSubjects(t *testing.T) {
+	serviceAccount := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "builder", Namespace: "tenant"}
+	tests := []struct {
+		name            string
+		nonUserSubjects string
+		subject         rbacv1.Subject
+	}{
+		{name: "group kept", nonUserSubjects: "keep", subject: groupSubject("team")},
+		{name: "group skipped", nonUserSubjects: "skip", subject: groupSubject("team")},
+		{name: "service account kept", nonUserSubjects: "keep", subject: serviceAccount},
+		{name: "service account skipped", nonUserSubjects: "skip", subject: serviceAccount},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, func(o *MigrateOptions) { o.NonUserSubjects = tt.nonUserSubjects })
+			rbList := []rbacv1.RoleBinding{tenantRoleBinding("tenant", "appstudio-viewer", "appstudio-viewer-user-actions", tt.subject)}
+
+			//The subject is not a kubesaw user, it must not be looked up
+			result := migrateRoleBindings(t, map[string]string{tt.subject.Name: "mangled"}, rbList, opts)
+
+			if tt.nonUserSubjects == "skip" {
+				if len(result.RoleBindings) != 0 {
+					t.Errorf("migrated %v, want none", result.RoleBindings)
+				}
+				return
+			}
+
+			if len(result.RoleBindings) != 1 {
+				t.Fatalf("migrated %d RoleBindings, want 1", len(result.RoleBindings))
+			}
+			if len(result.RoleBindings[0].Subjects) != 1 || result.RoleBindings[0].Subjects[0] != tt.subject {
+				t.Errorf("subjects = %v, want %v unchanged", result.RoleBindings[0].Subjects, tt.subject)
+			}
+			if result.RoleBindings[0].RoleRef.Name != "konflux-viewer-user-actions" {
+				t.Errorf("roleRef name = %s, want konflux-viewer-user-actions", result.RoleBindings[0].RoleRef.Name)
+			}
+		})
+	}
+}
+
+func TestMutateSkipsRoleBindingsWithoutSubjects(t *testing.T) {
+	opts := testOptions(t, nil)
+	rbList, err := opts.splitSubjects([]rbacv1.RoleBinding{
+		tenantRoleBinding("tenant", "appstudio-user-empty", "appstudio-user-actions"),
+		tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice")),
+	})
+	if err != nil {
+		t.Fatalf("splitSubjects() failed: %v", err)
+	}
+
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith"}, rbList, opts)
+
+	if len(result.RoleBindings) != 1 || result.RoleBindings[0].Subjects[0].Name != "asmith" {
+		t.Errorf("migrated %v, want only the RoleBinding of alice", result.RoleBindings)
+	}
+	if got := result.Namespaces[0].Skipped[SkipNoSubject]; got != 1 {
+		t.Errorf("skipped %d RoleBindings as %s, want 1", got, SkipNoSubject)
+	}
+}
+
+func TestSplitSubjectsNames(t *testing.T) {
+	tests := []struct {
+		name     string
+		source   rbacv1.RoleBinding
+		expected []string
+		migrated []string
+	}{
+		{
+			name:     "named after the first subject",
+			source:   tenantRoleBinding("tenant", "appstudio-admin-alice", "appstudio-admin-user-actions", userSubject("alice"), userSubject("bob")),
+			expected: []string{"appstudio-admin-alice", "appstudio-admin-bob"},
+			migrated: []string{"konflux-admin-asmith", "konflux-admin-bjones"},
+		},
+		{
+			name:     "named after every subject",
+			source:   tenantRoleBinding("tenant", "appstudio-admin-alice-bob", "appstudio-admin-user-actions", userSubject("alice"), userSubject("bob")),
+			expected: []string{"appstudio-admin-alice", "appstudio-admin-bob"},
+			migrated: []string{"konflux-admin-asmith", "konflux-admin-bjones"},
+		},
+		{
+			name:     "named after no subject",
+			source:   tenantRoleBinding("tenant", "appstudio-admins", "appstudio-admin-user-actions", userSubject("alice"), userSubject("bob")),
+			expected: []string{"appstudio-admins-alice", "appstudio-admins-bob"},
+			migrated: []string{"konflux-admins-asmith", "konflux-admins-bjones"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, nil)
+
+			rbList, err := opts.splitSubjects([]rbacv1.RoleBinding{tt.source})
+			if err != nil {
+				t.Fatalf("splitSubjects() failed: %v", err)
+			}
+			var names []string
+			for _, rb := range rbList {
+				names = append(names, rb.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("split into %v, want %v", names, tt.expected)
+			}
+
+			result := migrateRoleBindings(t, map[string]string{"alice": "asmith", "bob": "bjones"}, rbList, opts)
+			sortRoleBindings(result.RoleBindings)
+			var migrated []string
+			for _, rb := range result.RoleBindings {
+				migrated = append(migrated, rb.Name)
+			}
+			if !slices.Equal(migrated, tt.migrated) {
+				t.Errorf("migrated %v, want %v", migrated, tt.migrated)
+			}
+		})
+	}
+}
+
+func TestSplitSubjectsRejectsCollisions(t *testing.T) {
+	tests := []struct {
+		name   string
+		rbList []rbacv1.RoleBinding
+	}{
+		{
+			name: "subjects of the same name",
+			rbList: []rbacv1.RoleBinding{
+				tenantRoleBinding("tenant", "appstudio-admin-alice", "appstudio-admin-user-actions", userSubject("alice"), groupSubject("alice")),
+			},
+		},
+		{
+			name: "name of another RoleBinding",
+			rbList: []rbacv1.RoleBinding{
+				tenantRoleBinding("tenant", "appstudio-admin-alice", "appstudio-admin-user-actions", userSubject("alice"), userSubject("bob")),
+				tenantRoleBinding("tenant", "appstudio-admin-bob", "appstudio-viewer-user-actions", userSubject("bob")),
+			},
+		},
+		{
+			name: "name of another split RoleBinding",
+			rbList: []rbacv1.RoleBinding{
+				tenantRoleBinding("tenant", "appstudio-admin-alice", "appstudio-admin-user-actions", userSubject("alice"), userSubject("bob")),
+				tenantRoleBinding("tenant", "appstudio-admin-bob", "appstudio-viewer-user-actions", userSubject("bob"), userSubject("carol")),
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, nil)
+
+			if rbList, err := opts.splitSubjects(tt.rbList); err == nil {
+				t.Errorf("splitSubjects() = %v, want a name collision error", rbList)
+			}
+		})
+	}
+}
+
+func TestMutateUserSubject(t *testing.T) {
+	tests := []struct {
+		name     string
+		subject  rbacv1.Subject
+		apiGroup string
+		expected rbacv1.Subject
+	}{
+		{
+			name:     "kubesaw user",
+			subject:  userSubject("alice"),
+			apiGroup: rbacv1.GroupName,
+			expected: rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "asmith"},
+		},
+		{
+			name:     "legacy user without apiGroup",
+			subject:  rbacv1.Subject{Kind: rbacv1.UserKind, Name: "alice", Namespace: "tenant"},
+			apiGroup: rbacv1.GroupName,
+			expected: rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: "asmith"},
+		},
+		{
+			name:     "openshift user apiGroup",
+			subject:  userSubject("alice"),
+			apiGroup: "user.openshift.io",
+			expected: rbacv1.Subject{Kind: rbacv1.UserKind, APIGroup: "user.openshift.io", Name: "asmith"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, func(o *MigrateOptions) { o.SubjectAPIGroup = tt.apiGroup })
+			rbList := []rbacv1.RoleBinding{tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", tt.subject)}
+
+			result := migrateRoleBindings(t, map[string]string{"alice": "asmith"}, rbList, opts)
+
+			if len(result.RoleBindings) != 1 {
+				t.Fatalf("migrated %d RoleBindings, want 1", len(result.RoleBindings))
+			}
+			if len(result.RoleBindings[0].Subjects) != 1 || result.RoleBindings[0].Subjects[0] != tt.expected {
+				t.Errorf("subjects = %v, want %v", result.RoleBindings[0].Subjects, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMigratedOutputIsSorted(t *testing.T) {
+	var source []rbacv1.RoleBinding
+	idMap := make(map[string]string)
+	for _, namespace := range []string{"tenant-c", "tenant-a", "tenant-b"} {
+		for _, user := range []string{"carol", "alice", "bob"} {
+			source = append(source, tenantRoleBinding(namespace, "appstudio-user-"+user, "appstudio-user-actions", userSubject(user)))
+			idMap[user] = user + "-sso"
+		}
+	}
+
+	var expected []byte
+	for _, seed := range []int64{1, 2, 3} {
+		//The mutation writes through to the subjects, each run needs its own copy
+		rbList := make([]rbacv1.RoleBinding, 0, len(source))
+		for _, rb := range source {
+			rbList = append(rbList, *rb.DeepCopy())
+		}
+		rand.New(rand.NewSource(seed)).Shuffle(len(rbList), func(i, j int) { rbList[i], rbList[j] = rbList[j], rbList[i] })
+		opts := testOptions(t, func(o *MigrateOptions) { o.OutputFile = filepath.Join(t.TempDir(), "migrated.yaml") })
+
+		result := migrateRoleBindings(t, idMap, rbList, opts)
+		sortRoleBindings(result.RoleBindings)
+		if _, err := writeMigratedRoleBindings(result.RoleBindings, opts); err != nil {
+			t.Fatal(err)
+		}
+
+		var names []string
+		for _, rb := range result.RoleBindings {
+			names = append(names, rb.Namespace+"/"+rb.Name)
+		}
+		if !slices.IsSorted(names) {
+			t.Errorf("seed %d: RoleBindings are not sorted by Namespace then Name: %v", seed, names)
+		}
+
+		output, err := os.ReadFile(opts.OutputFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected == nil {
+			expected = output
+			continue
+		}
+		if !bytes.Equal(output, expected) {
+			t.Errorf("seed %d: output differs from the first run:\n%s\nwant:\n%s", seed, output, expected)
+		}
+	}
+}
+
+func TestToDNS1123Name(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "valid", input: "konflux-user-asmith", expected: "konflux-user-asmith"},
+		{name: "uppercase", input: "konflux-user-ASmith", expected: "konflux-user-asmith"},
+		{name: "underscore", input: "konflux-user-a_smith", expected: "konflux-user-a-smith"},
+		{name: "special characters", input: "konflux-user-a+smith@example.com", expected: "konflux-user-a-smith-example.com"},
+		{name: "trailing special character", input: "konflux-user-asmith_", expected: "konflux-user-asmith"},
+		{name: "too long", input: strings.Repeat("a", 300), expected: strings.Repeat("a", 253)},
+		{name: "nothing valid", input: "___", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toDNS1123Name(tt.input)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("toDNS1123Name(%q) = %q, want an error", tt.input, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("toDNS1123Name(%q) failed: %v", tt.input, err)
+			}
+			if got != tt.expected {
+				t.Errorf("toDNS1123Name(%q) = %q, want %q", tt.input, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestMutateSanitizesNames(t *testing.T) {
+	opts := testOptions(t, nil)
+	rbList := []rbacv1.RoleBinding{tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"))}
+
+	result := migrateRoleBindings(t, map[string]string{"alice": "A_Smith"}, rbList, opts)
+
+	if len(result.RoleBindings) != 1 {
+		t.Fatalf("migrated %d RoleBindings, want 1", len(result.RoleBindings))
+	}
+	if result.RoleBindings[0].Name != "konflux-user-a-smith" {
+		t.Errorf("name = %s, want konflux-user-a-smith", result.RoleBindings[0].Name)
+	}
+	//Only the name is sanitized, the subject is the sso id as is
+	if result.RoleBindings[0].Subjects[0].Name != "A_Smith" {
+		t.Errorf("subject = %s, want A_Smith", result.RoleBindings[0].Subjects[0].Name)
+	}
+}
+
+func TestCoerceEmail(t *testing.T) {
+	tests := []struct {
+		name     string
+		value    interface{}
+		expected string
+		wantErr  bool
+	}{
+		{name: "string", value: "alice@redhat.com", expected: "alice@redhat.com"},
+		{name: "integer", value: int64(42), expected: "42"},
+		{name: "float", value: 4.2, expected: "4.2"},
+		{name: "boolean", value: true, expected: "true"},
+		{name: "map", value: map[string]interface{}{"address": "alice@redhat.com"}, wantErr: true},
+		{name: "list", value: []interface{}{"alice@redhat.com"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := coerceEmail(tt.value)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("coerceEmail(%v) = %q, want an error", tt.value, got)
+				}
+				return
+			}
+			if err != nil || got != tt.expected {
+				t.Errorf("coerceEmail(%v) = %q, %v, want %q", tt.value, got, err, tt.expected)
+			}
+		})
+	}
+}
+
+func TestBuildIDMapMalformedEmails(t *testing.T) {
+	tests := []struct {
+		name  string
+		email interface{}
+	}{
+		{name: "nested map", email: map[string]interface{}{"address": "alice@redhat.com"}},
+		{name: "list", email: []interface{}{"alice@redhat.com"}},
+		{name: "null", email: nil},
+		{name: "number", email: int64(42)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, func(o *MigrateOptions) { o.Target = "email" })
+			accounts := &unstructured.UnstructuredList{Items: []unstructured.Unstructured{
+				userAccount("alice", tt.email),
+				userAccount("bob", "bob@redhat.com"),
+			}}
+
+			idMap, unresolved, err := buildIDMap(accounts, opts.transform, opts, context.Background())
+			if err != nil {
+				t.Fatalf("buildIDMap() failed: %v", err)
+			}
+			if !slices.Equal(unresolved, []string{"alice"}) {
+				t.Errorf("unresolved = %v, want [alice]", unresolved)
+			}
+			if idMap["bob"] != "bob@redhat.com" {
+				t.Errorf("idMap = %v, want bob still resolved", idMap)
+			}
+		})
+	}
+}
+
+func TestValidateRoleBinding(t *testing.T) {
+	valid := tenantRoleBinding("tenant", "konflux-user-asmith", "konflux-user-actions", userSubject("asmith"))
+	tests := []struct {
+		name    string
+		mutate  func(rb *rbacv1.RoleBinding)
+		wantErr bool
+	}{
+		{name: "valid", mutate: func(rb *rbacv1.RoleBinding) {}},
+		{name: "missing subject name", mutate: func(rb *rbacv1.RoleBinding) { rb.Subjects[0].Name = "" }, wantErr: true},
+		{name: "missing roleRef name", mutate: func(rb *rbacv1.RoleBinding) { rb.RoleRef.Name = "" }, wantErr: true},
+		{name: "no subject", mutate: func(rb *rbacv1.RoleBinding) { rb.Subjects = nil }, wantErr: true},
+		{name: "service account without namespace", mutate: func(rb *rbacv1.RoleBinding) {
+			rb.Subjects[0] = rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "builder"}
+		}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rb := valid.DeepCopy()
+			tt.mutate(rb)
+			if err := validateRoleBinding(rb); (err != nil) != tt.wantErr {
+				t.Errorf("validateRoleBinding() = %v, want an error: %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestMutateSkipsInvalidRoleBindings(t *testing.T) {
+	opts := testOptions(t, nil)
+	rbList := []rbacv1.RoleBinding{
+		tenantRoleBinding("tenant", "appstudio-user-alice", "", userSubject("alice")),
+		tenantRoleBinding("tenant", "appstudio-user-bob", "appstudio-user-actions", userSubject("bob")),
+	}
+
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith", "bob": "bjones"}, rbList, opts)
+
+	if len(result.RoleBindings) != 1 || result.RoleBindings[0].Subjects[0].Name != "bjones" {
+		t.Errorf("migrated %v, want only the RoleBinding of bob", result.RoleBindings)
+	}
+	if got := result.Namespaces[0].Skipped[SkipInvalidRoleBinding]; got != 1 {
+		t.Errorf("skipped %d RoleBindings as %s, want 1", got, SkipInvalidRoleBinding)
+	}
+}
+
+func TestGetTenantRoleBindingsListScope(t *testing.T) {
+	tests := []struct {
+		name       string
+		namespaces []string
+		nsList     []string
+		listed     string
+		expected   int
+	}{
+		{name: "all namespaces", nsList: []string{"tenant-a", "tenant-b"}, listed: metav1.NamespaceAll, expected: 2},
+		{name: "single namespace", namespaces: []string{"tenant-a"}, nsList: []string{"tenant-a"}, listed: "tenant-a", expected: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clientset := fake.NewClientset(
+				ptr(tenantRoleBinding("tenant-a", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"))),
+				ptr(tenantRoleBinding("tenant-b", "appstudio-user-bob", "appstudio-user-actions", userSubject("bob"))),
+			)
+			opts := testOptions(t, func(o *MigrateOptions) { o.Namespaces = tt.namespaces })
+
+			rbList, err := getTenantRoleBindings(clientset, tt.nsList, opts, context.Background())
+			if err != nil {
+				t.Fatalf("getTenantRoleBindings() failed: %v", err)
+			}
+			if len(rbList) != tt.expected {
+				t.Errorf("got %d RoleBindings, want %d", len(rbList), tt.expected)
+			}
+
+			//One list of the Tenant RoleBindings and one of the migrated ones
+			actions := clientset.Actions()
+			if len(actions) != 2 {
+				t.Fatalf("actions = %v, want two RoleBindings lists", actions)
+			}
+			for _, action := range actions {
+				if !action.Matches("list", "rolebindings") {
+					t.Fatalf("actions = %v, want only RoleBindings lists", actions)
+				}
+				if got := action.GetNamespace(); got != tt.listed {
+					t.Errorf("listed RoleBindings in namespace %q, want %q", got, tt.listed)
+				}
+			}
+		})
+	}
+}
+
+func TestGetTenantRoleBindingsNSConcurrency(t *testing.T) {
+	tenant := func(name string) *corev1.Namespace {
+		return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: map[string]string{"toolchain.dev.openshift.com/type": "tenant"}}}
+	}
+	viewer := tenantRoleBinding("tenant-a", "appstudio-viewer-carol", "appstudio-viewer-user-actions", userSubject("carol"))
+	objects := []runtime.Object{
+		tenant("tenant-a"), tenant("tenant-b"), tenant("tenant-c"),
+		&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "openshift-config"}},
+		ptr(tenantRoleBinding("tenant-a", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"))),
+		ptr(tenantRoleBinding("tenant-a", "appstudio-pipelines-runner-rolebinding", "appstudio-pipelines-runner", userSubject("runner"))),
+		&viewer,
+		ptr(tenantRoleBinding("tenant-b", "appstudio-user-bob", "appstudio-user-actions", userSubject("bob"))),
+		ptr(tenantRoleBinding("tenant-c", "appstudio-user-dave", "appstudio-user-actions", userSubject("dave"))),
+		//Carries the Tenant label outside a Tenant Namespace
+		ptr(tenantRoleBinding("openshift-config", "appstudio-user-eve", "appstudio-user-actions", userSubject("eve"))),
+	}
+
+	tests := []struct {
+		name   string
+		mutate func(o *MigrateOptions)
+	}{
+		{name: "all namespaces"},
+		{name: "single namespace", mutate: func(o *MigrateOptions) { o.Namespaces = []string{"tenant-b"} }},
+		{name: "several namespaces", mutate: func(o *MigrateOptions) { o.Namespaces = []string{"tenant-a", "tenant-c"} }},
+		{name: "non-tenant namespace", mutate: func(o *MigrateOptions) { o.Namespaces = []string{"openshift-config"} }},
+		{name: "role filter", mutate: func(o *MigrateOptions) { o.RoleFilter = "appstudio-user-*" }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var expected []string
+			for _, nsConcurrency := range []int{1, 3} {
+				opts := testOptions(t, func(o *MigrateOptions) {
+					if tt.mutate != nil {
+						tt.mutate(o)
+					}
+					o.NSConcurrency = nsConcurrency
+				})
+				clientset := fake.NewClientset(objects...)
+
+				nsList, err := getTenantNamespaces(clientset, opts, context.Background())
+				if err != nil {
+					t.Fatalf("getTenantNamespaces() failed: %v", err)
+				}
+				rbList, err := getTenantRoleBindings(clientset, nsList, opts, context.Background())
+				if err != nil {
+					t.Fatalf("getTenantRoleBindings() failed: %v", err)
+				}
+				sortRoleBindings(rbList)
+
+				names := []string{}
+				for _, rb := range rbList {
+					names = append(names, rb.Namespace+"/"+rb.Name)
+				}
+				if slices.Contains(names, "openshift-config/appstudio-user-eve") {
+					t.Errorf("with --ns-concurrency %d listed %v, want no RoleBinding outside the Tenant Namespaces", nsConcurrency, names)
+				}
+				if expected == nil {
+					expected = names
+					continue
+				}
+				if !slices.Equal(names, expected) {
+					t.Errorf("with --ns-concurrency %d listed %v, want %v as with --ns-concurrency 1", nsConcurrency, names, expected)
+				}
+			}
+		})
+	}
+}
+
+func TestMutateKeepsAnnotationPrefixes(t *testing.T) {
+	opts := testOptions(t, func(o *MigrateOptions) {
+		o.KeepAnnotationPrefixes = []string{"example.com/", "team"}
+	})
+	rb := tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"))
+	rb.Annotations = map[string]string{
+		"example.com/owner": "platform",
+		"team-name":         "builds",
+		"kubectl.kubernetes.io/last-applied-configuration": "{}",
+		"other.example.com/x":                              "dropped",
+	}
+
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith"}, []rbacv1.RoleBinding{rb}, opts)
+
+	if len(result.RoleBindings) != 1 {
+		t.Fatalf("migrated %d RoleBindings, want 1", len(result.RoleBindings))
+	}
+	expected := map[string]string{"example.com/owner": "platform", "team-name": "builds"}
+	if got := result.RoleBindings[0].Annotations; !maps.Equal(got, expected) {
+		t.Errorf("annotations = %v, want %v", got, expected)
+	}
+}
+
+func TestMutateReplaceAll(t *testing.T) {
+	tests := []struct {
+		name         string
+		replaceAll   bool
+		expectedName string
+		expectedRole string
+	}{
+		{
+			name:         "first match",
+			expectedName: "konflux-appstudio-asmith",
+			expectedRole: "konflux-appstudio-actions",
+		},
+		{
+			name:         "every match",
+			replaceAll:   true,
+			expectedName: "konflux-konflux-asmith",
+			expectedRole: "konflux-konflux-actions",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, func(o *MigrateOptions) { o.ReplaceAll = tt.replaceAll })
+			rbList := []rbacv1.RoleBinding{tenantRoleBinding("tenant", "appstudio-appstudio-alice", "appstudio-appstudio-actions", userSubject("alice"))}
+
+			result := migrateRoleBindings(t, map[string]string{"alice": "asmith"}, rbList, opts)
+
+			if len(result.RoleBindings) != 1 {
+				t.Fatalf("migrated %d RoleBindings, want 1", len(result.RoleBindings))
+			}
+			migrated := result.RoleBindings[0]
+			if migrated.Name != tt.expectedName {
+				t.Errorf("name = %q, want %q", migrated.Name, tt.expectedName)
+			}
+			if migrated.RoleRef.Name != tt.expectedRole {
+				t.Errorf("role = %q, want %q", migrated.RoleRef.Name, tt.expectedRole)
+			}
+		})
+	}
+}
+
+func TestMutateSkipsUnexpectedRoleRef(t *testing.T) {
+	opts := testOptions(t, nil)
+	unexpected := tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"))
+	unexpected.RoleRef = rbacv1.RoleRef{APIGroup: "example.com", Kind: "AggregatedRole", Name: "appstudio-user-actions"}
+	rbList := []rbacv1.RoleBinding{
+		unexpected,
+		tenantRoleBinding("tenant", "appstudio-user-bob", "appstudio-user-actions", userSubject("bob")),
+	}
+
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith", "bob": "bjones"}, rbList, opts)
+
+	if len(result.RoleBindings) != 1 || result.RoleBindings[0].Subjects[0].Name != "bjones" {
+		t.Errorf("migrated %v, want only the RoleBinding of bob", result.RoleBindings)
+	}
+	if got := result.Namespaces[0].Skipped[SkipUnexpectedRoleRef]; got != 1 {
+		t.Errorf("skipped %d RoleBindings as %s, want 1", got, SkipUnexpectedRoleRef)
+	}
+}
+
+func TestGetTenantRoleBindingsAlreadyMigrated(t *testing.T) {
+	idMap := map[string]string{"alice": "asmith", "bob": "bjones"}
+	source := tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"))
+	clientset := fake.NewClientset(
+		&source,
+		ptr(tenantRoleBinding("tenant", "appstudio-user-bob", "appstudio-user-actions", userSubject("bob"))),
+	)
+
+	//A previous run migrated alice only
+	previous := migrateRoleBindings(t, idMap, []rbacv1.RoleBinding{source}, testOptions(t, nil))
+	for _, rb := range previous.RoleBindings {
+		if _, err := clientset.RbacV1().RoleBindings(rb.Namespace).Create(context.Background(), &rb, metav1.CreateOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, nsConcurrency := range []int{1, 3} {
+		opts := testOptions(t, func(o *MigrateOptions) { o.NSConcurrency = nsConcurrency })
+		rbList, err := getTenantRoleBindings(clientset, []string{"tenant"}, opts, context.Background())
+		if err != nil {
+			t.Fatalf("getTenantRoleBindings() failed: %v", err)
+		}
+		if len(rbList) != 3 {
+			t.Fatalf("with --ns-concurrency %d listed %d RoleBindings, want the 2 Tenant ones and the migrated one", nsConcurrency, len(rbList))
+		}
+
+		result := migrateRoleBindings(t, idMap, rbList, opts)
+		if got := result.Namespaces[0].AlreadyMigrated; got != 1 {
+			t.Errorf("with --ns-concurrency %d reported %d RoleBindings already migrated, want 1", nsConcurrency, got)
+		}
+		if len(result.RoleBindings) != 2 {
+			t.Errorf("with --ns-concurrency %d migrated %d RoleBindings, want the 2 Tenant ones", nsConcurrency, len(result.RoleBindings))
+		}
+	}
+}
+
+func TestGetTenantRoleBindingsExtraSelector(t *testing.T) {
+	labeled := tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"))
+	labeled.Labels["team"] = "builds"
+	untenanted := tenantRoleBinding("tenant", "appstudio-user-carol", "appstudio-user-actions", userSubject("carol"))
+	untenanted.Labels = map[string]string{"team": "builds"}
+	clientset := fake.NewClientset(
+		&labeled,
+		ptr(tenantRoleBinding("tenant", "appstudio-user-bob", "appstudio-user-actions", userSubject("bob"))),
+		&untenanted,
+	)
+	opts := testOptions(t, func(o *MigrateOptions) { o.ExtraSelector = "team=builds" })
+
+	rbList, err := getTenantRoleBindings(clientset, []string{"tenant"}, opts, context.Background())
+	if err != nil {
+		t.Fatalf("getTenantRoleBindings() failed: %v", err)
+	}
+
+	if len(rbList) != 1 || rbList[0].Name != "appstudio-user-alice" {
+		t.Errorf("listed %v, want only the labeled Tenant RoleBinding", rbList)
+	}
+	expected, err := labels.Parse(tenantRoleBindingSelector + ",team=builds")
+	if err != nil {
+		t.Fatalf("labels.Parse() failed: %v", err)
+	}
+	list, ok := clientset.Actions()[0].(k8stesting.ListAction)
+	if !ok {
+		t.Fatalf("first action is %v, want a list", clientset.Actions()[0])
+	}
+	if got := list.GetListRestrictions().Labels.String(); got != expected.String() {
+		t.Errorf("label selector = %q, want %q", got, expected)
+	}
+}
+
+func TestRESTConfig(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	content := `apiVersion: v1
+kind: Config
+clusters:
+- name: member
+  cluster:
+    server: https://api.member.example.com:6443
+contexts:
+- name: member
+  context:
+    cluster: member
+    user: admin
+current-context: member
+users:
+- name: admin
+  user:
+    token: secret
+`
+	if err := os.WriteFile(kubeconfig, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	opts := &MigrateOptions{Kubeconfig: kubeconfig, As: "migrator", AsGroups: []string{"admins"}, ProxyURL: "http://proxy.example.com:3128"}
+
+	config, err := opts.RESTConfig()
+	if err != nil {
+		t.Fatalf("RESTConfig() failed: %v", err)
+	}
+	if config.Impersonate.UserName != "migrator" || !slices.Equal(config.Impersonate.Groups, []string{"admins"}) {
+		t.Errorf("impersonates %+v, want migrator of admins", config.Impersonate)
+	}
+	if config.Proxy == nil {
+		t.Fatal("proxy is not set, want --proxy-url")
+	}
+	proxyURL, err := config.Proxy(&http.Request{URL: &url.URL{Scheme: "https", Host: "api.member.example.com:6443"}})
+	if err != nil || proxyURL.String() != opts.ProxyURL {
+		t.Errorf("proxy = %v, %v, want %s", proxyURL, err, opts.ProxyURL)
+	}
+
+	opts.ProxyURL = "socks5://proxy.example.com:1080"
+	if _, err := opts.RESTConfig(); err == nil {
+		t.Error("RESTConfig() accepted a socks5 --proxy-url")
+	}
+}
+
+func TestDiffAgainstSplitSource(t *testing.T) {
+	opts := testOptions(t, func(o *MigrateOptions) {
+		o.Diff = true
+		o.DryRun = true
+	})
+	rbList := []rbacv1.RoleBinding{
+		tenantRoleBinding("tenant", "appstudio-admin-alice", "appstudio-admin-user-actions", userSubject("alice"), userSubject("bob")),
+	}
+
+	opts.recordDiffOrigins(rbList)
+	rbList, err := opts.splitSubjects(rbList)
+	if err != nil {
+		t.Fatalf("splitSubjects() failed: %v", err)
+	}
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith", "bob": "bjones"}, rbList, opts)
+
+	if len(result.Diffs) != 2 {
+		t.Fatalf("got %d diffs, want one per split RoleBinding", len(result.Diffs))
+	}
+	for _, diff := range result.Diffs {
+		if diff.Name != "appstudio-admin-alice" || !strings.Contains(diff.Diff, "--- a/tenant/appstudio-admin-alice\n") {
+			t.Errorf("diff of %s is against %s, want the source appstudio-admin-alice:\n%s", diff.Name, diff.Name, diff.Diff)
+		}
+		//Both subjects of the source are gone from each migrated RoleBinding
+		for _, subject := range []string{"alice", "bob"} {
+			if !strings.Contains(diff.Diff, "-  name: "+subject+"\n") {
+				t.Errorf("diff does not remove subject %s of the source:\n%s", subject, diff.Diff)
+			}
+		}
+		if strings.Contains(diff.Diff, splitAnnotation) {
+			t.Errorf("diff holds the internal %s annotation:\n%s", splitAnnotation, diff.Diff)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"text/template"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	"sigs.k8s.io/yaml"
+)
+
+// outputFormat renders the migrated RoleBindings for an --output-format value
+type outputFormat struct {
+	// header is written once before the first RoleBinding, may be nil
+	header func(opts *MigrateOptions) string
+	// empty replaces header when no RoleBinding is rendered, may be nil to keep header
+	empty func(opts *MigrateOptions) string
+	// render returns the chunk written for a single RoleBinding
+	render func(rb *rbacv1.RoleBinding, opts *MigrateOptions) ([]byte, error)
+	// ext is the file extension of the format, without the dot
+	ext string
+	// contentType is the media type of the format, sent with the --output-url uploads
+	contentType string
+	// comments reports whether # comment lines are valid in the format, to hold the provenance header
+	comments bool
+}
+
+var outputFormats = map[string]outputFormat{
+	"yaml":   {render: renderYAML, ext: "yaml", contentType: "application/yaml", comments: true},
+	"script": {header: scriptHeader, render: renderScript, ext: "sh", contentType: "text/x-shellscript", comments: true},
+	"jsonl":  {render: renderJSONL, ext: "jsonl", contentType: "application/jsonl"},
+	"ssa":    {header: ssaHeader, render: renderSSA, ext: "yaml", contentType: "application/yaml", comments: true},
+	"helm":   {header: helmHeader, empty: helmEmpty, render: renderHelm, ext: "yaml", contentType: "application/yaml", comments: true},
+}
+
+// helmValuesKey is the values.yaml key holding the list of HelmBinding
+const helmValuesKey = "bindings"
+
+// HelmBinding is an item of the --output-format helm values list. The schema is kept stable for chart authors:
+// fields may be added but are never renamed nor removed
+type HelmBinding struct {
+	Namespace string `json:"namespace"`
+	User      string `json:"user"`     // sso id of the User subject
+	Role      string `json:"role"`     // name of the target role
+	RoleKind  string `json:"roleKind"` // ClusterRole or Role
+}
+
+// FieldManager is the field manager recommended to server-side apply the migrated RoleBindings with,
+// and the one --apply writes them as
+const FieldManager = "konflux-rbac-migration"
+
+// lastAppliedAnnotation is the client-side apply record, which server-side apply would keep updating
+const lastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// outputFileData holds the variables available to the --output-file template
+type outputFileData struct {
+	Date    string // 2006-01-02
+	Time    string // 150405
+	Target  string
+	Context string
+}
+
+// fileNameReplacer swaps the path separators and colons of the kube contexts, e.g.
+// default/api-cluster:6443/admin, so they expand to a single file name
+var fileNameReplacer = strings.NewReplacer("/", "_", `\`, "_", ":", "_")
+
+// renderOutputFile expands the template placeholders of --output-file with the run metadata,
+// a path without placeholders is returned as is
+func (o *MigrateOptions) renderOutputFile(now time.Time) (string, error) {
+	if !strings.Contains(o.OutputFile, "{{") {
+		return o.OutputFile, nil
+	}
+
+	tmpl, err := template.New("output-file").Option("missingkey=error").Parse(o.OutputFile)
+	if err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+	data := outputFileData{Date: now.Format(time.DateOnly), Time: now.Format("150405"), Target: fileNameReplacer.Replace(o.Target), Context: fileNameReplacer.Replace(o.kubeContext)}
+	if err := tmpl.Execute(&sb, data); err != nil {
+		return "", err
+	}
+
+	return sb.String(), nil
+}
+
+// provenanceHeader returns the comment block recording how an output was generated, ignored by kubectl
+// apply as YAML comments
+func provenanceHeader(opts *MigrateOptions) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Generated by wscli migrate %s\n", toolVersion())
+	fmt.Fprintf(&sb, "# Date: %s\n", opts.startTime.UTC().Format(time.RFC3339))
+	if opts.kubeContext != "" {
+		fmt.Fprintf(&sb, "# Context: %s (cluster %s)\n", opts.kubeContext, opts.kubeCluster)
+	}
+	fmt.Fprintf(&sb, "# Selection: %s\n", opts.describeSelection())
+	fmt.Fprintf(&sb, "# Accounts: %d\n", opts.accountsTotal)
+
+	return sb.String()
+}
+
+// toolVersion returns the module version wscli was built from, (devel) for a local build
+func toolVersion() string {
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+// outputFormatNames returns the supported --output-format values
+func outputFormatNames() []string {
+	names := make([]string, 0, len(outputFormats))
+	for name := range outputFormats {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	return names
+}
+
+// renderYAML renders rb as a --- separated YAML document
+func renderYAML(rb *rbacv1.RoleBinding, opts *MigrateOptions) ([]byte, error) {
+	yamlData, err := encodeRoleBinding(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte("---\n"), yamlData...), nil
+}
+
+// ssaHeader documents the server-side apply command adopting the manifests under FieldManager
+func ssaHeader(opts *MigrateOptions) string {
+	return fmt.Sprintf("# Migrated RoleBindings for context %s, apply with:\n# kubectl apply --server-side --field-manager=%s -f <file>\n", opts.kubeContext, FieldManager)
+}
+
+// renderSSA renders rb as a YAML document ready for server-side apply: without managedFields nor the
+// client-side apply annotation, so the objects are owned by the field manager applying them only
+func renderSSA(rb *rbacv1.RoleBinding, opts *MigrateOptions) ([]byte, error) {
+	ssa := rb.DeepCopy()
+	ssa.ManagedFields = nil
+	delete(ssa.Annotations, lastAppliedAnnotation)
+	if len(ssa.Annotations) == 0 {
+		ssa.Annotations = nil
+	}
+
+	return renderYAML(ssa, opts)
+}
+
+// helmHeader opens the values list the HelmBinding items are appended to
+func helmHeader(opts *MigrateOptions) string {
+	return helmValuesKey + ":\n"
+}
+
+// helmEmpty is the values list without any HelmBinding, which a bare key would leave null
+func helmEmpty(opts *MigrateOptions) string {
+	return helmValuesKey + ": []\n"
+}
+
+// renderHelm renders the User subjects of rb as HelmBinding list items, other subjects have no place in the
+// schema and are left out with a warning. Nothing is rendered when rb has no User subject
+func renderHelm(rb *rbacv1.RoleBinding, opts *MigrateOptions) ([]byte, error) {
+	var items []HelmBinding
+	for _, subject := range rb.Subjects {
+		if subject.Kind != rbacv1.UserKind {
+			opts.logWarn(fmt.Sprintf("Leaving %s subject %s of RoleBinding %s in Namespace %s out of the helm values, they only hold users", subject.Kind, subject.Name, rb.Name, rb.Namespace), "namespace", rb.Namespace, "name", rb.Name, "kind", subject.Kind, "subject", subject.Name)
+			continue
+		}
+		items = append(items, HelmBinding{Namespace: rb.Namespace, User: subject.Name, Role: rb.RoleRef.Name, RoleKind: rb.RoleRef.Kind})
+	}
+	if len(items) == 0 {
+		return nil, nil
+	}
+
+	return yaml.Marshal(items)
+}
+
+// scriptHeader starts a shell script stopping at the first failing kubectl call
+func scriptHeader(opts *MigrateOptions) string {
+	return fmt.Sprintf("#!/bin/sh\n# Migrated RoleBindings for context %s\nset -e\n", opts.kubeContext)
+}
+
+// renderScript renders rb as a kubectl apply call against the target context, when known, with the YAML as heredoc
+func renderScript(rb *rbacv1.RoleBinding, opts *MigrateOptions) ([]byte, error) {
+	yamlData, err := encodeRoleBinding(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "\n# RoleBinding %s in Namespace %s\n", rb.Name, rb.Namespace)
+	//Without a context kubectl applies to its current one, an empty --context would fail the call
+	if opts.kubeContext == "" {
+		sb.WriteString("kubectl apply -f - <<'EOF'\n")
+	} else {
+		fmt.Fprintf(&sb, "kubectl --context %s apply -f - <<'EOF'\n", shellQuote(opts.kubeContext))
+	}
+	sb.Write(yamlData)
+	sb.WriteString("EOF\n")
+
+	return []byte(sb.String()), nil
+}
+
+// shellQuote single quotes s for sh, closing the quotes around each ' it holds
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// renderJSONL renders rb as a single line JSON object, ready for jq
+func renderJSONL(rb *rbacv1.RoleBinding, opts *MigrateOptions) ([]byte, error) {
+	obj, err := roleBindingObject(rb)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(data, '\n'), nil
+}
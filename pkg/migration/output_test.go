@@ -0,0 +1,141 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestWriteMigratedRoleBindingsWithoutCreationTimestamp(t *testing.T) {
+	var rbList []rbacv1.RoleBinding
+	idMap := make(map[string]string)
+	for _, user := range []string{"alice", "bob", "carol"} {
+		rbList = append(rbList, tenantRoleBinding("tenant", "appstudio-user-"+user, "appstudio-user-actions", userSubject(user)))
+		idMap[user] = user + "-sso"
+	}
+	opts := testOptions(t, func(o *MigrateOptions) { o.OutputFile = filepath.Join(t.TempDir(), "migrated.yaml") })
+
+	result := migrateRoleBindings(t, idMap, rbList, opts)
+	if _, err := writeMigratedRoleBindings(result.RoleBindings, opts); err != nil {
+		t.Fatal(err)
+	}
+
+	output, err := os.ReadFile(opts.OutputFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Count(string(output), "kind: RoleBinding"); got != len(rbList) {
+		t.Errorf("wrote %d RoleBindings, want %d", got, len(rbList))
+	}
+	if strings.Contains(string(output), "creationTimestamp") {
+		t.Errorf("output holds a creationTimestamp:\n%s", output)
+	}
+}
+
+func TestRenderOutputFileSanitizesContext(t *testing.T) {
+	opts := &MigrateOptions{OutputFile: "out/{{ .Context }}-{{ .Target }}-{{ .Date }}.yaml", Target: `corp\user`}
+	opts.kubeContext = "default/api-cluster:6443/admin"
+
+	got, err := opts.renderOutputFile(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("renderOutputFile() failed: %v", err)
+	}
+
+	expected := "out/default_api-cluster_6443_admin-corp_user-2025-01-02.yaml"
+	if got != expected {
+		t.Errorf("renderOutputFile() = %q, want %q", got, expected)
+	}
+}
+
+func TestRenderHelm(t *testing.T) {
+	serviceAccount := rbacv1.Subject{Kind: rbacv1.ServiceAccountKind, Name: "builder", Namespace: "tenant"}
+	tests := []struct {
+		name     string
+		rbList   []rbacv1.RoleBinding
+		expected string
+		written  int
+	}{
+		{
+			name:     "empty",
+			expected: "bindings: []\n",
+		},
+		{
+			name:     "only non-user subjects",
+			rbList:   []rbacv1.RoleBinding{tenantRoleBinding("tenant", "konflux-builder", "konflux-builder", serviceAccount)},
+			expected: "bindings: []\n",
+		},
+		{
+			name: "non-user subject left out",
+			rbList: []rbacv1.RoleBinding{
+				tenantRoleBinding("tenant", "konflux-builder", "konflux-builder", serviceAccount),
+				tenantRoleBinding("tenant", "konflux-user-asmith", "konflux-user-actions", userSubject("asmith")),
+			},
+			expected: "bindings:\n- namespace: tenant\n  role: konflux-user-actions\n  roleKind: ClusterRole\n  user: asmith\n",
+			written:  1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := testOptions(t, func(o *MigrateOptions) {
+				o.OutputFormat = "helm"
+				o.OutputHeader = false
+			})
+
+			var buf bytes.Buffer
+			written, err := renderRoleBindings(&buf, tt.rbList, opts, true)
+			if err != nil {
+				t.Fatalf("renderRoleBindings() failed: %v", err)
+			}
+
+			if buf.String() != tt.expected {
+				t.Errorf("rendered %q, want %q", buf.String(), tt.expected)
+			}
+			if written != tt.written {
+				t.Errorf("wrote %d RoleBindings, want %d", written, tt.written)
+			}
+		})
+	}
+}
+
+func TestRenderScriptQuotesContext(t *testing.T) {
+	opts := testOptions(t, func(o *MigrateOptions) { o.OutputFormat = "script" })
+	opts.kubeContext = "ops's-cluster"
+	rb := tenantRoleBinding("tenant", "konflux-user-asmith", "konflux-user-actions", userSubject("asmith"))
+
+	data, err := renderScript(&rb, opts)
+	if err != nil {
+		t.Fatalf("renderScript() failed: %v", err)
+	}
+
+	expected := `kubectl --context 'ops'\''s-cluster' apply -f - <<'EOF'`
+	if !strings.Contains(string(data), expected+"\n") {
+		t.Errorf("rendered %q, want the context quoted as %s", data, expected)
+	}
+}
+
+func TestRenderScriptWithoutContext(t *testing.T) {
+	opts := testOptions(t, func(o *MigrateOptions) { o.OutputFormat = "script" })
+	rb := tenantRoleBinding("tenant", "konflux-user-asmith", "konflux-user-actions", userSubject("asmith"))
+
+	data, err := renderScript(&rb, opts)
+	if err != nil {
+		t.Fatalf("renderScript() failed: %v", err)
+	}
+
+	if strings.Contains(string(data), "--context") {
+		t.Errorf("rendered %q, want no --context without a kube context", data)
+	}
+	if !strings.Contains(string(data), "kubectl apply -f - <<'EOF'\n") {
+		t.Errorf("rendered %q, want a kubectl apply of the current context", data)
+	}
+}
@@ -0,0 +1,151 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// DefaultPruneRecord is the file recording the source RoleBindings deleted by --prune
+const DefaultPruneRecord = "pruned_rolebindings.yaml"
+
+// pruneSourceRoleBindings deletes the source RoleBindings whose derived RoleBindings, counted in derived by
+// countDerived, were all migrated and applied, the ones in failed and the split ones are kept. The sources are written to
+// --prune-record before any deletion so they can be restored with kubectl apply
+func pruneSourceRoleBindings(clientset kubernetes.Interface, mappings []RoleBindingMapping, derived map[string]derivation, failed map[string]bool, opts *MigrateOptions, stats *MigrationStats, ctx context.Context) error {
+	//Split and expanded sources map to several RoleBindings, a skipped one leaves its subject without a migrated binding
+	keep := make(map[string]bool)
+	applied := make(map[string]int)
+	for _, m := range mappings {
+		key := m.OldNamespace + "/" + m.OldName
+		//A migrated RoleBinding named like its source replaced it in place
+		if failed[m.OldNamespace+"/"+m.NewName] || m.NewName == m.OldName {
+			keep[key] = true
+		}
+		applied[key]++
+	}
+
+	var sources []rbacv1.RoleBinding
+	seen := make(map[string]bool)
+	for _, m := range mappings {
+		key := m.OldNamespace + "/" + m.OldName
+		if keep[key] || seen[key] {
+			continue
+		}
+		seen[key] = true
+		if derived[key].split {
+			opts.logInfo(fmt.Sprintf("Keeping source RoleBinding %s in Namespace %s, it was split by subject", m.OldName, m.OldNamespace), "namespace", m.OldNamespace, "name", m.OldName)
+			continue
+		}
+		if count := derived[key].count; applied[key] < count {
+			opts.logInfo(fmt.Sprintf("Keeping source RoleBinding %s in Namespace %s, %d of its %d RoleBindings were not migrated", m.OldName, m.OldNamespace, count-applied[key], count), "namespace", m.OldNamespace, "name", m.OldName)
+			continue
+		}
+
+		source, err := clientset.RbacV1().RoleBindings(m.OldNamespace).Get(ctx, m.OldName, metav1.GetOptions{})
+		if apierrors.IsNotFound(err) {
+			continue
+		}
+		if err != nil {
+			return interruptedOr(ctx, fmt.Errorf("failed to get source RoleBinding %s in Namespace %s: %w", m.OldName, m.OldNamespace, err))
+		}
+		sources = append(sources, *source)
+	}
+
+	if len(sources) == 0 {
+		opts.logInfo("No source RoleBindings to prune")
+		return nil
+	}
+
+	if opts.ConfirmPrune == nil || !opts.ConfirmPrune(len(sources)) {
+		opts.logWarn(fmt.Sprintf("Not pruning %d source RoleBindings, pruning was not confirmed", len(sources)), "count", len(sources))
+		return nil
+	}
+
+	//A dry run deletes nothing, so the record of an earlier run is kept for its rollback
+	if opts.DryRun {
+		opts.logInfo(fmt.Sprintf("Not writing --prune-record %s in a dry run", opts.PruneRecord), "file", opts.PruneRecord)
+	} else {
+		if err := writePruneRecord(opts.PruneRecord, sources); err != nil {
+			return fmt.Errorf("failed to write --prune-record: %w", err)
+		}
+		opts.logInfo(fmt.Sprintf("Recorded %d source RoleBindings to prune in %s", len(sources), opts.PruneRecord), "count", len(sources), "file", opts.PruneRecord)
+	}
+
+	var dryRunOpt []string
+	if opts.DryRun {
+		dryRunOpt = []string{metav1.DryRunAll}
+	}
+
+	for _, source := range sources {
+		//The UID precondition keeps a RoleBinding recreated since the Get from being deleted
+		err := clientset.RbacV1().RoleBindings(source.Namespace).Delete(ctx, source.Name, metav1.DeleteOptions{
+			DryRun:        dryRunOpt,
+			Preconditions: &metav1.Preconditions{UID: &source.UID},
+		})
+		if err != nil && !apierrors.IsNotFound(err) {
+			return interruptedOr(ctx, fmt.Errorf("failed to prune source RoleBinding %s in Namespace %s: %w", source.Name, source.Namespace, err))
+		}
+
+		opts.logInfo(fmt.Sprintf("Pruned source RoleBinding %s in Namespace %s", source.Name, source.Namespace), "namespace", source.Namespace, "name", source.Name, "dry_run", opts.DryRun)
+		stats.RoleBindingsPruned++
+	}
+
+	return nil
+}
+
+// derivation tallies the RoleBindings derived from a source RoleBinding
+type derivation struct {
+	count int
+	split bool // split by subject, such sources are never pruned
+}
+
+// countDerived tallies the RoleBindings of rbList derived from each source RoleBinding, keyed by namespace/name
+func countDerived(rbList []rbacv1.RoleBinding) map[string]derivation {
+	derived := make(map[string]derivation)
+	for _, rb := range rbList {
+		key := rb.Namespace + "/" + sourceName(&rb)
+		d := derived[key]
+		d.count++
+		d.split = d.split || rb.Annotations[splitAnnotation] != ""
+		derived[key] = d
+	}
+	return derived
+}
+
+// writePruneRecord writes the sources to path as a YAML stream, without the server-set metadata so that
+// kubectl apply restores them
+func writePruneRecord(path string, sources []rbacv1.RoleBinding) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	for _, source := range sources {
+		source.ResourceVersion = ""
+		source.UID = ""
+		source.ManagedFields = nil
+		source.APIVersion = "rbac.authorization.k8s.io/v1"
+		source.Kind = "RoleBinding"
+
+		data, err := renderYAML(&source, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := file.Write(data); err != nil {
+			return err
+		}
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,174 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func TestPruneSourceRoleBindings(t *testing.T) {
+	tests := []struct {
+		name   string
+		source rbacv1.RoleBinding
+		expand map[string][]string
+		idMap  map[string]string
+		pruned bool
+	}{
+		{
+			name:   "single subject migrated",
+			source: tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice")),
+			idMap:  map[string]string{"alice": "asmith"},
+			pruned: true,
+		},
+		{
+			name:   "single subject unresolved",
+			source: tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice")),
+			idMap:  map[string]string{},
+		},
+		{
+			name:   "split with an unresolved subject",
+			source: tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"), userSubject("bob")),
+			idMap:  map[string]string{"alice": "asmith"},
+		},
+		{
+			name:   "group with every member migrated",
+			source: tenantRoleBinding("tenant", "appstudio-team", "appstudio-user-actions", groupSubject("team")),
+			expand: map[string][]string{"team": {"alice", "bob"}},
+			idMap:  map[string]string{"alice": "asmith", "bob": "bjones"},
+			pruned: true,
+		},
+		{
+			name:   "group with an unresolved member",
+			source: tenantRoleBinding("tenant", "appstudio-team", "appstudio-user-actions", groupSubject("team")),
+			expand: map[string][]string{"team": {"alice", "bob"}},
+			idMap:  map[string]string{"alice": "asmith"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clientset := fake.NewClientset(tt.source.DeepCopy())
+			opts := testOptions(t, func(o *MigrateOptions) {
+				o.Apply = true
+				o.Prune = true
+				o.PruneRecord = filepath.Join(t.TempDir(), "pruned.yaml")
+				o.ConfirmPrune = func(int) bool { return true }
+			})
+
+			rbList, err := opts.splitSubjects([]rbacv1.RoleBinding{tt.source})
+			if err != nil {
+				t.Fatalf("splitSubjects() failed: %v", err)
+			}
+			if tt.expand != nil {
+				rbList = expandGroupSubjects(rbList, tt.expand)
+			}
+			derived := countDerived(rbList)
+			result := migrateRoleBindings(t, tt.idMap, rbList, opts)
+
+			if err := pruneSourceRoleBindings(clientset, result.Mappings, derived, map[string]bool{}, opts, &result.Stats, ctx); err != nil {
+				t.Fatalf("pruneSourceRoleBindings() failed: %v", err)
+			}
+
+			_, err = clientset.RbacV1().RoleBindings(tt.source.Namespace).Get(ctx, tt.source.Name, metav1.GetOptions{})
+			if pruned := apierrors.IsNotFound(err); pruned != tt.pruned {
+				t.Errorf("source pruned = %v, want %v", pruned, tt.pruned)
+			}
+		})
+	}
+}
+
+func TestPruneSourceRoleBindingsKeepsFailed(t *testing.T) {
+	ctx := context.Background()
+	source := tenantRoleBinding("tenant", "appstudio-team", "appstudio-user-actions", groupSubject("team"))
+	clientset := fake.NewClientset(source.DeepCopy())
+	opts := testOptions(t, func(o *MigrateOptions) {
+		o.Apply = true
+		o.Prune = true
+		o.PruneRecord = filepath.Join(t.TempDir(), "pruned.yaml")
+		o.ConfirmPrune = func(int) bool { return true }
+	})
+
+	rbList := expandGroupSubjects([]rbacv1.RoleBinding{source}, map[string][]string{"team": {"alice", "bob"}})
+	derived := countDerived(rbList)
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith", "bob": "bjones"}, rbList, opts)
+	failed := map[string]bool{"tenant/" + result.Mappings[1].NewName: true}
+
+	if err := pruneSourceRoleBindings(clientset, result.Mappings, derived, failed, opts, &result.Stats, ctx); err != nil {
+		t.Fatalf("pruneSourceRoleBindings() failed: %v", err)
+	}
+	if _, err := clientset.RbacV1().RoleBindings("tenant").Get(ctx, source.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("source with a failed RoleBinding was pruned: %v", err)
+	}
+}
+
+func TestPruneSourceRoleBindingsDryRunKeepsRecord(t *testing.T) {
+	ctx := context.Background()
+	source := tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"))
+	clientset := fake.NewClientset(source.DeepCopy())
+	record := filepath.Join(t.TempDir(), "pruned.yaml")
+	previous := []byte("# record of an earlier run\n")
+	if err := os.WriteFile(record, previous, 0666); err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(t, func(o *MigrateOptions) {
+		o.Apply = true
+		o.DryRun = true
+		o.Prune = true
+		o.PruneRecord = record
+		o.ConfirmPrune = func(int) bool { return true }
+	})
+
+	rbList := []rbacv1.RoleBinding{source}
+	derived := countDerived(rbList)
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith"}, rbList, opts)
+
+	if err := pruneSourceRoleBindings(clientset, result.Mappings, derived, map[string]bool{}, opts, &result.Stats, ctx); err != nil {
+		t.Fatalf("pruneSourceRoleBindings() failed: %v", err)
+	}
+
+	content, err := os.ReadFile(record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != string(previous) {
+		t.Errorf("dry run overwrote --prune-record with:\n%s", content)
+	}
+}
+
+func TestPruneSourceRoleBindingsKeepsSplit(t *testing.T) {
+	ctx := context.Background()
+	//The split RoleBinding of alice keeps the source name
+	source := tenantRoleBinding("tenant", "appstudio-user-alice", "appstudio-user-actions", userSubject("alice"), userSubject("bob"))
+	clientset := fake.NewClientset(source.DeepCopy())
+	opts := testOptions(t, func(o *MigrateOptions) {
+		o.Apply = true
+		o.Prune = true
+		o.PruneRecord = filepath.Join(t.TempDir(), "pruned.yaml")
+		o.ConfirmPrune = func(int) bool { return true }
+	})
+
+	rbList, err := opts.splitSubjects([]rbacv1.RoleBinding{source})
+	if err != nil {
+		t.Fatalf("splitSubjects() failed: %v", err)
+	}
+	derived := countDerived(rbList)
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith", "bob": "bjones"}, rbList, opts)
+
+	if err := pruneSourceRoleBindings(clientset, result.Mappings, derived, map[string]bool{}, opts, &result.Stats, ctx); err != nil {
+		t.Fatalf("pruneSourceRoleBindings() failed: %v", err)
+	}
+	if _, err := clientset.RbacV1().RoleBindings("tenant").Get(ctx, source.Name, metav1.GetOptions{}); err != nil {
+		t.Errorf("split source was pruned: %v", err)
+	}
+}
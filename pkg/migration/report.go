@@ -0,0 +1,182 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// RoleBindingMapping records which source RoleBinding became which migrated RoleBinding
+type RoleBindingMapping struct {
+	OldNamespace string `json:"old_namespace"`
+	OldName      string `json:"old_name"`               // source RoleBinding on the cluster, also for the split or expanded ones
+	DerivedName  string `json:"derived_name,omitempty"` // single-subject RoleBinding split or expanded from OldName, empty otherwise
+	OldSubject   string `json:"old_subject"`
+	OldRole      string `json:"old_role"`
+	NewName      string `json:"new_name"`
+	NewSubject   string `json:"new_subject"`
+	NewRole      string `json:"new_role"`
+}
+
+// writeMappingReport writes the mappings to path as a JSON array when it has a .json extension, as CSV otherwise
+func writeMappingReport(path string, mappings []RoleBindingMapping) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == ".json" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(mappings)
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"old_namespace", "old_name", "derived_name", "old_subject", "old_role", "new_name", "new_subject", "new_role"})
+	for _, m := range mappings {
+		writer.Write([]string{m.OldNamespace, m.OldName, m.DerivedName, m.OldSubject, m.OldRole, m.NewName, m.NewSubject, m.NewRole})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// Reasons a source RoleBinding was skipped, keys of NamespaceSummary.Skipped
+const (
+	SkipUnresolved         = "unresolved"
+	SkipNotAllowed         = "not-allowed"
+	SkipNonUserSubject     = "non-user-subject"
+	SkipUnknownKind        = "unknown-kind"
+	SkipInvalidName        = "invalid-name"
+	SkipDuplicate          = "duplicate"
+	SkipExcluded           = "excluded"
+	SkipInvalidRoleBinding = "invalid-rolebinding"
+	SkipUnmappedRole       = "unmapped-role"
+	SkipNotSampled         = "not-sampled"
+	SkipUnexpectedRoleRef  = "unexpected-roleref"
+	SkipNoSubject          = "no-subject"
+	SkipEmptyGroup         = "empty-group"
+)
+
+// NamespaceSummary breaks down what happened to the source RoleBindings of a Tenant Namespace
+type NamespaceSummary struct {
+	Namespace       string         `json:"namespace"`
+	Source          int            `json:"source"` // source RoleBindings on the cluster, not counting their splits or expansions
+	Migrated        int            `json:"migrated"`
+	AlreadyMigrated int            `json:"already_migrated"`
+	Skipped         map[string]int `json:"skipped,omitempty"` // count per skip reason
+}
+
+// skip counts a source RoleBinding skipped for reason
+func (s *NamespaceSummary) skip(reason string) {
+	if s.Skipped == nil {
+		s.Skipped = make(map[string]int)
+	}
+	s.Skipped[reason]++
+}
+
+// SkippedTotal returns the number of skipped source RoleBindings
+func (s *NamespaceSummary) SkippedTotal() int {
+	total := 0
+	for _, count := range s.Skipped {
+		total += count
+	}
+	return total
+}
+
+// SkipReasons formats the skip reasons as "reason=count" pairs sorted by reason
+func (s *NamespaceSummary) SkipReasons() string {
+	reasons := make([]string, 0, len(s.Skipped))
+	for _, reason := range slices.Sorted(maps.Keys(s.Skipped)) {
+		reasons = append(reasons, fmt.Sprintf("%s=%d", reason, s.Skipped[reason]))
+	}
+	return strings.Join(reasons, ";")
+}
+
+// writeNamespaceReport writes the summaries to path as a JSON array when it has a .json extension, as CSV otherwise
+func writeNamespaceReport(path string, summaries []NamespaceSummary) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == ".json" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(summaries)
+	}
+
+	writer := csv.NewWriter(file)
+	writer.Write([]string{"namespace", "source", "migrated", "already_migrated", "skipped", "skip_reasons"})
+	for _, s := range summaries {
+		writer.Write([]string{s.Namespace, strconv.Itoa(s.Source), strconv.Itoa(s.Migrated), strconv.Itoa(s.AlreadyMigrated), strconv.Itoa(s.SkippedTotal()), s.SkipReasons()})
+	}
+	writer.Flush()
+
+	return writer.Error()
+}
+
+// Reasons a Tenant Namespace was left without any migrated RoleBinding
+const (
+	OrphanNoBindings   = "no-bindings"   // no Tenant RoleBinding was found in the Namespace
+	OrphanNoneMigrated = "none-migrated" // every Tenant RoleBinding of the Namespace was skipped
+)
+
+// OrphanNamespace is a Tenant Namespace left without any migrated RoleBinding
+type OrphanNamespace struct {
+	Namespace string `json:"namespace"`
+	Reason    string `json:"reason"`
+}
+
+// orphanNamespaces returns the Namespaces of summaries left without any migrated RoleBinding, telling the
+// ones without source RoleBindings from the ones whose source RoleBindings were all skipped
+func orphanNamespaces(summaries []NamespaceSummary) []OrphanNamespace {
+	orphans := []OrphanNamespace{}
+	for _, s := range summaries {
+		if s.Migrated+s.AlreadyMigrated > 0 {
+			continue
+		}
+		reason := OrphanNoneMigrated
+		if s.Source == 0 {
+			reason = OrphanNoBindings
+		}
+		orphans = append(orphans, OrphanNamespace{Namespace: s.Namespace, Reason: reason})
+	}
+	return orphans
+}
+
+// writeOrphanReport writes the orphans to path as a JSON array when it has a .json extension, as one
+// tab-separated "namespace reason" line each otherwise
+func writeOrphanReport(path string, orphans []OrphanNamespace) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if filepath.Ext(path) == ".json" {
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(orphans)
+	}
+
+	for _, o := range orphans {
+		if _, err := fmt.Fprintf(file, "%s\t%s\n", o.Namespace, o.Reason); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
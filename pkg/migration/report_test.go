@@ -0,0 +1,69 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestMappingReportNamesSources(t *testing.T) {
+	opts := testOptions(t, nil)
+	rbList, err := opts.splitSubjects([]rbacv1.RoleBinding{
+		tenantRoleBinding("tenant", "appstudio-admin-alice", "appstudio-admin-user-actions", userSubject("alice"), userSubject("bob")),
+		tenantRoleBinding("tenant", "appstudio-team", "appstudio-user-actions", groupSubject("team")),
+		tenantRoleBinding("tenant", "appstudio-viewer-carol", "appstudio-viewer-user-actions", userSubject("carol")),
+	})
+	if err != nil {
+		t.Fatalf("splitSubjects() failed: %v", err)
+	}
+	rbList = expandGroupSubjects(rbList, map[string][]string{"team": {"dave", "erin"}})
+
+	idMap := map[string]string{"alice": "asmith", "bob": "bjones", "carol": "cdoe", "dave": "dlee", "erin": "ewu"}
+	result := migrateRoleBindings(t, idMap, rbList, opts)
+
+	path := filepath.Join(t.TempDir(), "mappings.csv")
+	if err := writeMappingReport(path, result.Mappings); err != nil {
+		t.Fatalf("writeMappingReport() failed: %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	records, err := csv.NewReader(file).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{
+		"asmith": {"appstudio-admin-alice", "appstudio-admin-alice"},
+		"bjones": {"appstudio-admin-alice", "appstudio-admin-bob"},
+		"cdoe":   {"appstudio-viewer-carol", ""},
+		"dlee":   {"appstudio-team", "appstudio-dave"},
+		"ewu":    {"appstudio-team", "appstudio-erin"},
+	}
+	if len(records) != len(expected)+1 {
+		t.Fatalf("report holds %d lines, want a header and %d mappings:\n%v", len(records), len(expected), records)
+	}
+	for _, record := range records[1:] {
+		//old_name and derived_name, keyed by new_subject
+		if got, want := record[1:3], expected[record[6]]; !slices.Equal(got, want) {
+			t.Errorf("mapping of %s names %v, want %v", record[6], got, want)
+		}
+	}
+
+	if got := result.Namespaces[0].Source; got != 3 {
+		t.Errorf("summary counts %d source RoleBindings, want the 3 on the cluster", got)
+	}
+	if got := result.Namespaces[0].Migrated; got != 5 {
+		t.Errorf("summary counts %d migrated RoleBindings, want 5", got)
+	}
+}
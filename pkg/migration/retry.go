@@ -0,0 +1,60 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	utilnet "k8s.io/apimachinery/pkg/util/net"
+)
+
+const (
+	// listInitialDelay is the wait before the second attempt of a List call, doubled on each retry
+	listInitialDelay = time.Second
+	// listMaxDelay caps the wait between two attempts of a List call
+	listMaxDelay = 30 * time.Second
+)
+
+// isTransientError reports whether err is an API server throttling or availability error, or a dropped connection
+func isTransientError(err error) bool {
+	return apierrors.IsTooManyRequests(err) ||
+		apierrors.IsServerTimeout(err) ||
+		apierrors.IsTimeout(err) ||
+		apierrors.IsServiceUnavailable(err) ||
+		apierrors.IsInternalError(err) ||
+		utilnet.IsConnectionReset(err) ||
+		utilnet.IsProbableEOF(err)
+}
+
+// listWithRetry calls list until it succeeds, fails with a non transient error or --list-max-attempts
+// attempts were made. It waits the Retry-After delay suggested by the server, an exponential backoff otherwise
+func listWithRetry(what string, opts *MigrateOptions, ctx context.Context, list func() error) error {
+	delay := listInitialDelay
+	for attempt := 1; ; attempt++ {
+		err := list()
+		if err == nil || attempt >= opts.ListMaxAttempts || !isTransientError(err) {
+			return err
+		}
+
+		wait := delay
+		if seconds, ok := apierrors.SuggestsClientDelay(err); ok {
+			wait = time.Duration(seconds) * time.Second
+		}
+
+		opts.logWarn(fmt.Sprintf("Failed to list %s (attempt %d/%d), retrying in %s: %v", what, attempt, opts.ListMaxAttempts, wait, err),
+			"resource", what, "attempt", attempt, "error", err)
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(wait):
+		}
+
+		delay = min(delay*2, listMaxDelay)
+	}
+}
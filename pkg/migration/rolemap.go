@@ -0,0 +1,87 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"fmt"
+	"os"
+
+	"sigs.k8s.io/yaml"
+)
+
+// roleMapEntry maps a source role to the role migrated RoleBindings reference, in every Tenant
+// Namespace or, when Namespace is set, only in that one
+type roleMapEntry struct {
+	Source    string `json:"source"`
+	Namespace string `json:"namespace,omitempty"`
+	Kind      string `json:"kind,omitempty"` // ClusterRole when empty
+	Name      string `json:"name"`
+}
+
+// roleTarget is the role referenced by a migrated RoleBinding
+type roleTarget struct {
+	Kind string
+	Name string
+}
+
+// roleMapKey indexes the role map, Namespace is empty for global entries
+type roleMapKey struct {
+	Namespace string
+	Source    string
+}
+
+type roleMap map[roleMapKey]roleTarget
+
+// loadRoleMap reads the --role-map YAML or JSON file, a list of entries with source, name and the
+// optional namespace and kind. An empty path returns an empty map
+func loadRoleMap(path string) (roleMap, error) {
+	rm := make(roleMap)
+	if path == "" {
+		return rm, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []roleMapEntry
+	if err := yaml.UnmarshalStrict(data, &entries); err != nil {
+		return nil, err
+	}
+
+	for i, entry := range entries {
+		if entry.Source == "" || entry.Name == "" {
+			return nil, fmt.Errorf("entry %d: source and name are required", i+1)
+		}
+
+		kind := entry.Kind
+		switch kind {
+		case "":
+			kind = "ClusterRole"
+		case "ClusterRole", "Role":
+		default:
+			return nil, fmt.Errorf("entry %d: kind must be ClusterRole or Role, got %s", i+1, kind)
+		}
+
+		key := roleMapKey{Namespace: entry.Namespace, Source: entry.Source}
+		if _, exists := rm[key]; exists {
+			return nil, fmt.Errorf("entry %d: duplicate mapping for role %s in namespace %q", i+1, entry.Source, entry.Namespace)
+		}
+		rm[key] = roleTarget{Kind: kind, Name: entry.Name}
+	}
+
+	return rm, nil
+}
+
+// lookup returns the target of role in namespace, preferring the namespace specific entry over the global one
+func (rm roleMap) lookup(namespace string, role string) (roleTarget, bool) {
+	if target, ok := rm[roleMapKey{Namespace: namespace, Source: role}]; ok {
+		return target, true
+	}
+
+	target, ok := rm[roleMapKey{Source: role}]
+	return target, ok
+}
@@ -0,0 +1,83 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+// testRoleMap maps appstudio-admin-user-actions to a ClusterRole, and to a Role in tenant-a
+const testRoleMap = `
+- source: appstudio-admin-user-actions
+  name: konflux-admin-user-actions
+- source: appstudio-admin-user-actions
+  namespace: tenant-a
+  kind: Role
+  name: tenant-a-admin
+`
+
+func TestRoleMapLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "role-map.yaml")
+	if err := os.WriteFile(path, []byte(testRoleMap), 0666); err != nil {
+		t.Fatal(err)
+	}
+	rm, err := loadRoleMap(path)
+	if err != nil {
+		t.Fatalf("loadRoleMap() failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		namespace string
+		role      string
+		expected  roleTarget
+		found     bool
+	}{
+		{name: "namespace specific", namespace: "tenant-a", role: "appstudio-admin-user-actions", expected: roleTarget{Kind: "Role", Name: "tenant-a-admin"}, found: true},
+		{name: "global fallback", namespace: "tenant-b", role: "appstudio-admin-user-actions", expected: roleTarget{Kind: "ClusterRole", Name: "konflux-admin-user-actions"}, found: true},
+		{name: "unmapped", namespace: "tenant-a", role: "appstudio-viewer-user-actions"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			target, found := rm.lookup(tt.namespace, tt.role)
+			if found != tt.found || target != tt.expected {
+				t.Errorf("lookup(%s, %s) = %v, %v, want %v, %v", tt.namespace, tt.role, target, found, tt.expected, tt.found)
+			}
+		})
+	}
+}
+
+func TestMutateRoleMap(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "role-map.yaml")
+	if err := os.WriteFile(path, []byte(testRoleMap), 0666); err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(t, func(o *MigrateOptions) { o.RoleMap = path })
+	rbList := []rbacv1.RoleBinding{
+		tenantRoleBinding("tenant-a", "appstudio-admin-alice", "appstudio-admin-user-actions", userSubject("alice")),
+		tenantRoleBinding("tenant-b", "appstudio-admin-alice", "appstudio-admin-user-actions", userSubject("alice")),
+	}
+
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith"}, rbList, opts)
+	sortRoleBindings(result.RoleBindings)
+
+	expected := []rbacv1.RoleRef{
+		{APIGroup: rbacv1.GroupName, Kind: "Role", Name: "tenant-a-admin"},
+		{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: "konflux-admin-user-actions"},
+	}
+	if len(result.RoleBindings) != len(expected) {
+		t.Fatalf("migrated %d RoleBindings, want %d", len(result.RoleBindings), len(expected))
+	}
+	for i, rb := range result.RoleBindings {
+		if rb.RoleRef != expected[i] {
+			t.Errorf("roleRef in %s = %v, want %v", rb.Namespace, rb.RoleRef, expected[i])
+		}
+	}
+}
@@ -0,0 +1,59 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+// validateTargetRoles checks every distinct role referenced by the migrated RoleBindings exists on the
+// cluster. Missing roles are warned about, or returned as an error with --validate-roles error
+func validateTargetRoles(clientset kubernetes.Interface, rbList []rbacv1.RoleBinding, opts *MigrateOptions, ctx context.Context) error {
+	checked := make(map[string]bool)
+	missing := 0
+
+	for _, rb := range rbList {
+		var key string
+		var err error
+		switch rb.RoleRef.Kind {
+		case "Role":
+			key = fmt.Sprintf("Role %s in Namespace %s", rb.RoleRef.Name, rb.Namespace)
+			if checked[key] {
+				continue
+			}
+			_, err = clientset.RbacV1().Roles(rb.Namespace).Get(ctx, rb.RoleRef.Name, metav1.GetOptions{})
+		default:
+			key = fmt.Sprintf("ClusterRole %s", rb.RoleRef.Name)
+			if checked[key] {
+				continue
+			}
+			_, err = clientset.RbacV1().ClusterRoles().Get(ctx, rb.RoleRef.Name, metav1.GetOptions{})
+		}
+		checked[key] = true
+
+		if apierrors.IsNotFound(err) {
+			opts.logWarn(fmt.Sprintf("Target %s referenced by RoleBinding %s does not exist", key, rb.Name), "namespace", rb.Namespace, "name", rb.Name, "role", rb.RoleRef.Name)
+			missing++
+			continue
+		}
+		if err != nil {
+			return interruptedOr(ctx, fmt.Errorf("failed to get target %s: %w", key, err))
+		}
+	}
+
+	if missing > 0 && opts.ValidateRoles == "error" {
+		return fmt.Errorf("%d target roles referenced by migrated RoleBindings do not exist", missing)
+	}
+
+	opts.logInfo(fmt.Sprintf("Validated %d target roles, %d missing", len(checked), missing), "count", len(checked), "missing", missing)
+
+	return nil
+}
@@ -0,0 +1,225 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/rest"
+)
+
+// SpaceBindingGVR is the resource of the kubesaw SpaceBindings granting a MasterUserRecord access to a Space
+var SpaceBindingGVR = schema.GroupVersionResource{
+	Group:    "toolchain.dev.openshift.com",
+	Version:  "v1alpha1",
+	Resource: "spacebindings",
+}
+
+// MasterUserRecordGVR is the resource of the kubesaw MasterUserRecords
+var MasterUserRecordGVR = schema.GroupVersionResource{
+	Group:    "toolchain.dev.openshift.com",
+	Version:  "v1alpha1",
+	Resource: "masteruserrecords",
+}
+
+// SpaceGVR is the resource of the kubesaw Spaces, whose status lists the Namespaces provisioned for them
+var SpaceGVR = schema.GroupVersionResource{
+	Group:    "toolchain.dev.openshift.com",
+	Version:  "v1alpha1",
+	Resource: "spaces",
+}
+
+// spaceRoleClusterRole is the ClusterRole the kubesaw appstudio tiers bind for a space role, renamed by --role-transform
+const spaceRoleClusterRole = "appstudio-%s-user-actions"
+
+// spaceBinding holds the fields of a SpaceBinding read by the migration
+type spaceBinding struct {
+	Name              string
+	MasterUserRecord  string
+	Space             string
+	SpaceRole         string
+	CreationTimestamp metav1.Time
+}
+
+// hostDynamicClient returns the dynamic client of the host cluster, reached with --host-kubeconfig when set
+// and with config otherwise
+func hostDynamicClient(config *rest.Config, o *MigrateOptions) (dynamic.Interface, error) {
+	if o.HostKubeconfig != "" {
+		if err := ValidateKubeconfig(o.HostKubeconfig); err != nil {
+			return nil, fmt.Errorf("invalid --host-kubeconfig: %w", err)
+		}
+		hostConfig, err := KubeClientConfig(o.HostKubeconfig).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to load --host-kubeconfig: %w", err)
+		}
+		hostConfig.Impersonate = config.Impersonate
+		hostConfig.Proxy = config.Proxy
+		config = hostConfig
+	}
+
+	dynclient, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create host k8s client: %w", err)
+	}
+	return dynclient, nil
+}
+
+// listSpaceBindings lists the SpaceBindings of --spacebinding-namespace, leaving out the ones without a MasterUserRecord
+func listSpaceBindings(dynclient dynamic.Interface, o *MigrateOptions, ctx context.Context) ([]spaceBinding, error) {
+	var list *unstructured.UnstructuredList
+	err := listWithRetry("space bindings", o, ctx, func() (err error) {
+		list, err = dynclient.Resource(SpaceBindingGVR).Namespace(o.SpaceBindingNamespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, interruptedOr(ctx, fmt.Errorf("failed to list space bindings: %w", err))
+	}
+
+	spaceBindings := make([]spaceBinding, 0, len(list.Items))
+	for _, item := range list.Items {
+		sb := spaceBinding{Name: item.GetName(), CreationTimestamp: item.GetCreationTimestamp()}
+		sb.MasterUserRecord, _, _ = unstructured.NestedString(item.Object, "spec", "masterUserRecord")
+		sb.Space, _, _ = unstructured.NestedString(item.Object, "spec", "space")
+		sb.SpaceRole, _, _ = unstructured.NestedString(item.Object, "spec", "spaceRole")
+		if sb.MasterUserRecord == "" {
+			o.logWarn(fmt.Sprintf("SpaceBinding %s: masterUserRecord not found", sb.Name), "spacebinding", sb.Name)
+			continue
+		}
+		spaceBindings = append(spaceBindings, sb)
+	}
+
+	return spaceBindings, nil
+}
+
+// listSpaceBindingAccounts lists the SpaceBindings of the host cluster and returns the MasterUserRecords
+// they reference, which carry the same name and propagated claims as the member UserAccounts
+func listSpaceBindingAccounts(config *rest.Config, o *MigrateOptions, ctx context.Context) (*unstructured.UnstructuredList, error) {
+	dynclient, err := hostDynamicClient(config, o)
+	if err != nil {
+		return nil, err
+	}
+
+	spaceBindings, err := listSpaceBindings(dynclient, o, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	murs := make(map[string]bool)
+	spaces := make(map[string]bool)
+	for _, sb := range spaceBindings {
+		murs[sb.MasterUserRecord] = true
+		spaces[sb.Space] = true
+	}
+	o.logInfo(fmt.Sprintf("Found %d space bindings of %d master user records to %d spaces in %s namespace", len(spaceBindings), len(murs), len(spaces), o.SpaceBindingNamespace), "count", len(spaceBindings), "murs", len(murs), "spaces", len(spaces), "namespace", o.SpaceBindingNamespace)
+
+	var murList *unstructured.UnstructuredList
+	err = listWithRetry("master user records", o, ctx, func() (err error) {
+		murList, err = dynclient.Resource(MasterUserRecordGVR).Namespace(o.SpaceBindingNamespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, interruptedOr(ctx, fmt.Errorf("failed to list master user records: %w", err))
+	}
+
+	accounts := &unstructured.UnstructuredList{}
+	for _, mur := range murList.Items {
+		if murs[mur.GetName()] {
+			accounts.Items = append(accounts.Items, mur)
+			delete(murs, mur.GetName())
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(murs)) {
+		o.logWarn(fmt.Sprintf("MasterUserRecord %s is bound to a space but was not found", name), "mur", name)
+	}
+
+	if len(accounts.Items) == 0 {
+		if o.RequireAccounts {
+			return nil, fmt.Errorf("no bound master user records found in %s namespace, check --spacebinding-namespace, --host-kubeconfig and the RBAC to list %s and %s", o.SpaceBindingNamespace, SpaceBindingGVR.GroupResource(), MasterUserRecordGVR.GroupResource())
+		}
+		o.logWarn(fmt.Sprintf("No bound master user records found in %s namespace, nothing will be migrated. Check --spacebinding-namespace, --host-kubeconfig and the RBAC to list %s and %s", o.SpaceBindingNamespace, SpaceBindingGVR.GroupResource(), MasterUserRecordGVR.GroupResource()), "namespace", o.SpaceBindingNamespace)
+	}
+
+	return accounts, nil
+}
+
+// getSpaceBindingRoleBindings derives the source RoleBindings of the Tenant Namespaces nsList from the host
+// SpaceBindings, one per SpaceBinding in each Namespace provisioned for its Space. The RoleBinding binds the
+// MasterUserRecord, resolved like a UserAccount of the same name, to the space role: through its --role-map
+// entry when there is one, to the ClusterRole of the appstudio tiers renamed by --role-transform otherwise
+func getSpaceBindingRoleBindings(dynclient dynamic.Interface, nsList []string, o *MigrateOptions, ctx context.Context) ([]rbacv1.RoleBinding, error) {
+	spaceBindings, err := listSpaceBindings(dynclient, o, ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var spaceList *unstructured.UnstructuredList
+	err = listWithRetry("spaces", o, ctx, func() (err error) {
+		spaceList, err = dynclient.Resource(SpaceGVR).Namespace(o.SpaceBindingNamespace).List(ctx, metav1.ListOptions{})
+		return err
+	})
+	if err != nil {
+		return nil, interruptedOr(ctx, fmt.Errorf("failed to list spaces: %w", err))
+	}
+
+	spaceNamespaces := make(map[string][]string, len(spaceList.Items))
+	for _, space := range spaceList.Items {
+		provisioned, _, _ := unstructured.NestedSlice(space.Object, "status", "provisionedNamespaces")
+		for _, item := range provisioned {
+			if namespace, ok := item.(map[string]interface{}); ok {
+				if name, ok := namespace["name"].(string); ok && name != "" {
+					spaceNamespaces[space.GetName()] = append(spaceNamespaces[space.GetName()], name)
+				}
+			}
+		}
+	}
+
+	var rbs []rbacv1.RoleBinding
+	for _, sb := range spaceBindings {
+		if sb.SpaceRole == "" {
+			o.logWarn(fmt.Sprintf("SpaceBinding %s: spaceRole not found", sb.Name), "spacebinding", sb.Name)
+			continue
+		}
+		namespaces, ok := spaceNamespaces[sb.Space]
+		if !ok {
+			o.logWarn(fmt.Sprintf("SpaceBinding %s: space %s has no provisioned Namespace", sb.Name, sb.Space), "spacebinding", sb.Name, "space", sb.Space)
+			continue
+		}
+		for _, namespace := range namespaces {
+			rbs = append(rbs, o.spaceBindingRoleBinding(sb, namespace))
+		}
+	}
+	o.logInfo(fmt.Sprintf("Derived %d RoleBindings from %d space bindings", len(rbs), len(spaceBindings)), "count", len(rbs), "spacebindings", len(spaceBindings))
+
+	return o.selectRoleBindings(inNamespaces(rbs, nsList)), nil
+}
+
+// spaceBindingRoleBinding returns the source RoleBinding sb grants in namespace, named like the kubesaw Tenant
+// RoleBindings so --name-template applies unchanged. It records sb as its source
+func (o *MigrateOptions) spaceBindingRoleBinding(sb spaceBinding, namespace string) rbacv1.RoleBinding {
+	role := sb.SpaceRole
+	if _, ok := o.roleMap.lookup(namespace, role); !ok {
+		role = fmt.Sprintf(spaceRoleClusterRole, sb.SpaceRole)
+	}
+
+	return rbacv1.RoleBinding{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("appstudio-%s-%s", sb.SpaceRole, sb.MasterUserRecord),
+			Namespace:   namespace,
+			Annotations: map[string]string{sourceAnnotation: sb.Name},
+			//Read by --created-after
+			CreationTimestamp: sb.CreationTimestamp,
+		},
+		RoleRef:  rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: role},
+		Subjects: []rbacv1.Subject{{Kind: rbacv1.UserKind, APIGroup: rbacv1.GroupName, Name: sb.MasterUserRecord}},
+	}
+}
@@ -0,0 +1,135 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+)
+
+// hostObject returns a kubesaw object of the host operator namespace
+func hostObject(kind string, name string, fields map[string]interface{}) *unstructured.Unstructured {
+	object := map[string]interface{}{
+		"apiVersion": "toolchain.dev.openshift.com/v1alpha1",
+		"kind":       kind,
+		"metadata":   map[string]interface{}{"name": name, "namespace": "toolchain-host-operator"},
+	}
+	for key, value := range fields {
+		object[key] = value
+	}
+	return &unstructured.Unstructured{Object: object}
+}
+
+// testSpaceBinding returns a SpaceBinding of mur to space with spaceRole
+func testSpaceBinding(name string, mur string, space string, spaceRole string) *unstructured.Unstructured {
+	return hostObject("SpaceBinding", name, map[string]interface{}{
+		"spec": map[string]interface{}{"masterUserRecord": mur, "space": space, "spaceRole": spaceRole},
+	})
+}
+
+// testSpace returns a Space provisioned in namespaces
+func testSpace(name string, namespaces ...string) *unstructured.Unstructured {
+	provisioned := make([]interface{}, 0, len(namespaces))
+	for _, namespace := range namespaces {
+		provisioned = append(provisioned, map[string]interface{}{"name": namespace, "type": "default"})
+	}
+	return hostObject("Space", name, map[string]interface{}{
+		"status": map[string]interface{}{"provisionedNamespaces": provisioned},
+	})
+}
+
+func TestGetSpaceBindingRoleBindings(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "role-map.yaml")
+	roleMap := "- source: contributor\n  name: konflux-maintainer-user-actions\n"
+	if err := os.WriteFile(path, []byte(roleMap), 0666); err != nil {
+		t.Fatal(err)
+	}
+	opts := testOptions(t, func(o *MigrateOptions) {
+		o.Source = "spacebinding"
+		o.RoleMap = path
+	})
+	dynclient := dynamicfake.NewSimpleDynamicClientWithCustomListKinds(runtime.NewScheme(),
+		map[schema.GroupVersionResource]string{SpaceBindingGVR: "SpaceBindingList", SpaceGVR: "SpaceList"},
+		testSpace("team-a", "team-a-tenant"),
+		testSpace("team-b", "team-b-tenant"),
+		testSpaceBinding("alice-admin", "alice", "team-a", "admin"),
+		testSpaceBinding("bob-contributor", "bob", "team-a", "contributor"),
+		//Provisioned on another member cluster
+		testSpaceBinding("carol-viewer", "carol", "team-b", "viewer"),
+		testSpaceBinding("dave-admin", "dave", "team-gone", "admin"),
+		testSpaceBinding("erin-none", "erin", "team-a", ""),
+	)
+
+	rbList, err := getSpaceBindingRoleBindings(dynclient, []string{"team-a-tenant"}, opts, context.Background())
+	if err != nil {
+		t.Fatalf("getSpaceBindingRoleBindings() failed: %v", err)
+	}
+	if len(rbList) != 2 {
+		t.Fatalf("derived %v, want the RoleBindings of alice and bob in team-a-tenant", rbList)
+	}
+
+	result := migrateRoleBindings(t, map[string]string{"alice": "asmith", "bob": "bjones"}, rbList, opts)
+	sortRoleBindings(result.RoleBindings)
+
+	expected := []struct {
+		name string
+		role string
+		id   string
+	}{
+		{name: "konflux-admin-asmith", role: "konflux-admin-user-actions", id: "asmith"},
+		{name: "konflux-contributor-bjones", role: "konflux-maintainer-user-actions", id: "bjones"},
+	}
+	if len(result.RoleBindings) != len(expected) {
+		t.Fatalf("migrated %d RoleBindings, want %d", len(result.RoleBindings), len(expected))
+	}
+	for i, rb := range result.RoleBindings {
+		if rb.Namespace != "team-a-tenant" || rb.Name != expected[i].name || rb.RoleRef.Name != expected[i].role || rb.Subjects[0].Name != expected[i].id {
+			t.Errorf("migrated %s/%s binding %s to %s, want team-a-tenant/%s binding %s to %s", rb.Namespace, rb.Name, rb.Subjects[0].Name, rb.RoleRef.Name, expected[i].name, expected[i].id, expected[i].role)
+		}
+		if rb.RoleRef.Kind != "ClusterRole" {
+			t.Errorf("roleRef kind of %s = %s, want ClusterRole", rb.Name, rb.RoleRef.Kind)
+		}
+	}
+
+	for _, m := range result.Mappings {
+		if m.OldName != "alice-admin" && m.OldName != "bob-contributor" {
+			t.Errorf("mapping old_name = %s, want the SpaceBinding", m.OldName)
+		}
+	}
+	if got := result.Namespaces[0].Source; got != 2 {
+		t.Errorf("summary counts %d sources, want the 2 SpaceBindings", got)
+	}
+}
+
+func TestSpaceBindingSourceRejectsRoleBindingOptions(t *testing.T) {
+	tests := []struct {
+		name   string
+		mutate func(o *MigrateOptions)
+	}{
+		{name: "input file", mutate: func(o *MigrateOptions) { o.InputFile = "rolebindings.yaml" }},
+		{name: "prune", mutate: func(o *MigrateOptions) { o.Apply, o.Prune = true, true }},
+		{name: "watch", mutate: func(o *MigrateOptions) { o.Apply, o.Watch = true, true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultMigrateOptions()
+			opts.Source = "spacebinding"
+			tt.mutate(&opts)
+
+			if err := opts.compile(); !errors.Is(err, ErrInvalidOptions) {
+				t.Errorf("compile() = %v, want %v", err, ErrInvalidOptions)
+			}
+		})
+	}
+}
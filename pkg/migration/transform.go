@@ -0,0 +1,66 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// transforms is the registry of the --transform names
+var transforms = map[string]Transform{
+	"clean-email": cleanEmailTransform,
+	"ldap-uid":    (*MigrateOptions).getUser,
+}
+
+// claimTransformPrefix selects the value of another UserAccount claim as the id, as in claim:preferred_username
+const claimTransformPrefix = "claim:"
+
+// RegisterTransform makes transform selectable by name with --transform and --target-override,
+// it is fed with the --email-claim of each UserAccount. It is not safe to call concurrently with Run
+func RegisterTransform(name string, transform Transform) {
+	transforms[name] = transform
+}
+
+// TransformNames returns the registered --transform names, claim:<name> is accepted on top of them
+func TransformNames() []string {
+	return slices.Sorted(maps.Keys(transforms))
+}
+
+// transformer is a resolved --transform along with the claim it is fed with
+type transformer struct {
+	name      string
+	claim     string
+	transform Transform
+}
+
+// claimTransform returns the claim value as is, only trimming the spaces around it
+func claimTransform(o *MigrateOptions, value string, ctx context.Context) (string, error) {
+	return strings.TrimSpace(value), nil
+}
+
+// lookupTransform resolves a --transform name, or a --target value standing for one, to its transformer
+func (o *MigrateOptions) lookupTransform(name string) (transformer, error) {
+	if alias, ok := targetTransforms[name]; ok {
+		name = alias
+	}
+
+	if claim, ok := strings.CutPrefix(name, claimTransformPrefix); ok {
+		if claim == "" {
+			return transformer{}, fmt.Errorf("%s needs a claim name", name)
+		}
+		return transformer{name: name, claim: claim, transform: claimTransform}, nil
+	}
+
+	transform, ok := transforms[name]
+	if !ok {
+		return transformer{}, fmt.Errorf("unknown transform %q, select one of %s or %s<name>", name, strings.Join(TransformNames(), ", "), claimTransformPrefix)
+	}
+
+	return transformer{name: name, claim: o.EmailClaim, transform: transform}, nil
+}
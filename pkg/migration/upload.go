@@ -0,0 +1,136 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+)
+
+// outputSink stores the rendered output, of the contentType media type, at the destination of an --output-url
+type outputSink func(u *url.URL, data []byte, contentType string, ctx context.Context) error
+
+// outputSinks maps the remote --output-url schemes to their sink, file:// is written as an --output-file
+var outputSinks = map[string]outputSink{
+	"http":  putHTTP,
+	"https": putHTTP,
+	"s3":    putS3,
+}
+
+// parseOutputURL parses an --output-url, rejecting schemes without a sink
+func parseOutputURL(rawURL string) (*url.URL, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := outputSinks[u.Scheme]; !ok && u.Scheme != "file" {
+		return nil, fmt.Errorf("unsupported scheme %q, use file://, https:// or s3://", u.Scheme)
+	}
+
+	return u, nil
+}
+
+// putHTTP uploads data with an HTTP PUT, e.g. to a pre-signed object storage URL
+func putHTTP(u *url.URL, data []byte, contentType string, ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, u.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+
+	return doUpload(req)
+}
+
+// putS3 uploads data to the s3://bucket/key object with a SigV4 signed PUT. Credentials and region are
+// read from the standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY, AWS_SESSION_TOKEN and AWS_REGION
+// variables, AWS_ENDPOINT_URL_S3 selects an S3 compatible endpoint addressed path-style
+func putS3(u *url.URL, data []byte, contentType string, ctx context.Context) error {
+	accessKey, secretKey := os.Getenv("AWS_ACCESS_KEY_ID"), os.Getenv("AWS_SECRET_ACCESS_KEY")
+	if accessKey == "" || secretKey == "" {
+		return fmt.Errorf("AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY must be set to upload to %s", u)
+	}
+
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	bucket, key := u.Host, strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" {
+		return fmt.Errorf("expected s3://bucket/key, got %s", u)
+	}
+
+	endpoint := &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", bucket, region), Path: "/" + key}
+	if custom := os.Getenv("AWS_ENDPOINT_URL_S3"); custom != "" {
+		base, err := url.Parse(custom)
+		if err != nil {
+			return fmt.Errorf("invalid AWS_ENDPOINT_URL_S3: %w", err)
+		}
+		endpoint = base.JoinPath(bucket, key)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, endpoint.String(), bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	if err := signS3Request(req, data, accessKey, secretKey, os.Getenv("AWS_SESSION_TOKEN"), region, time.Now()); err != nil {
+		return fmt.Errorf("failed to sign the S3 request: %w", err)
+	}
+
+	return doUpload(req)
+}
+
+// signS3Request adds the AWS Signature Version 4 headers of an S3 request with data as payload
+func signS3Request(req *http.Request, data []byte, accessKey, secretKey, sessionToken, region string, now time.Time) error {
+	payloadHash := sha256Hex(data)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds := aws.Credentials{AccessKeyID: accessKey, SecretAccessKey: secretKey, SessionToken: sessionToken}
+	//S3 signs the path as sent, without the second escaping of the other services
+	signer := v4.NewSigner(func(o *v4.SignerOptions) { o.DisableURIPathEscaping = true })
+
+	return signer.SignHTTP(req.Context(), creds, req, payloadHash, "s3", region, now)
+}
+
+// doUpload sends req, turning a non 2xx response into an error
+func doUpload(req *http.Request) error {
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("upload to %s failed: %s", displayURL(req.URL), resp.Status)
+	}
+
+	return nil
+}
+
+// displayURL returns u without its query and credentials, which may hold a pre-signed signature
+func displayURL(u *url.URL) string {
+	shown := *u
+	shown.RawQuery = ""
+	shown.User = nil
+	return shown.String()
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
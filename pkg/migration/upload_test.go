@@ -0,0 +1,116 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+)
+
+func TestFileOutputURLIsGuarded(t *testing.T) {
+	existing := filepath.Join(t.TempDir(), "migrated.yaml")
+	if err := os.WriteFile(existing, []byte("kept"), 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		mutate  func(o *MigrateOptions)
+		refused bool
+	}{
+		{name: "existing file", refused: true},
+		{name: "force", mutate: func(o *MigrateOptions) { o.Force = true }},
+		{name: "append", mutate: func(o *MigrateOptions) { o.Append = true }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts := DefaultMigrateOptions()
+			opts.OutputURL = "file://" + existing
+			if tt.mutate != nil {
+				tt.mutate(&opts)
+			}
+
+			err := opts.compile()
+			if tt.refused {
+				if err == nil || !strings.Contains(err.Error(), "refusing to overwrite") {
+					t.Fatalf("compile() = %v, want a refusal to overwrite", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("compile() failed: %v", err)
+			}
+			if opts.OutputFile != existing || opts.outputURL != nil {
+				t.Errorf("output file = %q, url = %v, want %q written as an --output-file", opts.OutputFile, opts.outputURL, existing)
+			}
+		})
+	}
+}
+
+func TestSignS3Request(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, "https://bucket.s3.eu-west-1.amazonaws.com/out/migrated.yaml", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = signS3Request(req, []byte("data"), "AKID", "SECRET", "TOKEN", "eu-west-1", time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("signS3Request() failed: %v", err)
+	}
+
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=AKID/20250102/eu-west-1/s3/aws4_request") {
+		t.Errorf("Authorization = %q, want the AKID credential scoped to eu-west-1 s3", auth)
+	}
+	for _, header := range []string{"X-Amz-Date", "X-Amz-Content-Sha256", "X-Amz-Security-Token"} {
+		if req.Header.Get(header) == "" {
+			t.Errorf("%s header is missing", header)
+		}
+	}
+}
+
+func TestUploadContentType(t *testing.T) {
+	tests := []struct {
+		format      string
+		contentType string
+	}{
+		{format: "yaml", contentType: "application/yaml"},
+		{format: "ssa", contentType: "application/yaml"},
+		{format: "helm", contentType: "application/yaml"},
+		{format: "jsonl", contentType: "application/jsonl"},
+		{format: "script", contentType: "text/x-shellscript"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var contentType string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				contentType = r.Header.Get("Content-Type")
+			}))
+			defer server.Close()
+			opts := testOptions(t, func(o *MigrateOptions) {
+				o.OutputFormat = tt.format
+				o.OutputURL = server.URL + "/migrated"
+			})
+			rb := tenantRoleBinding("tenant", "konflux-user-asmith", "konflux-user-actions", userSubject("asmith"))
+
+			if _, err := uploadMigratedRoleBindings([]rbacv1.RoleBinding{rb}, opts, context.Background()); err != nil {
+				t.Fatalf("uploadMigratedRoleBindings() failed: %v", err)
+			}
+			if contentType != tt.contentType {
+				t.Errorf("uploaded with Content-Type %q, want %q", contentType, tt.contentType)
+			}
+		})
+	}
+}
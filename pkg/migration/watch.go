@@ -0,0 +1,187 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/cache"
+)
+
+// watchRoleBindings migrates and applies the Tenant RoleBindings created after the run started, as an informer
+// on the Tenant RoleBinding selector reports them, until ctx is done. idMap is refreshed every --watch-refresh.
+// The informer lists the RoleBindings then watches from the resource version of that list, relisting on its
+// own when the watch expires; only the RoleBindings it had not seen yet are reported as added. Cancelling ctx,
+// e.g. with SIGINT or SIGTERM, stops the watch cleanly and is not an error
+func watchRoleBindings(idMap map[string]string, config *rest.Config, opts *MigrateOptions, result *Result, ctx context.Context) error {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return fmt.Errorf("failed to create k8s client: %w", err)
+	}
+	var dynclient dynamic.Interface
+	if opts.ExpandGroups {
+		dynclient, err = dynamic.NewForConfig(config)
+		if err != nil {
+			return fmt.Errorf("failed to create k8s client: %w", err)
+		}
+	}
+
+	factoryOpts := []informers.SharedInformerOption{
+		informers.WithTweakListOptions(func(listOpts *metav1.ListOptions) {
+			listOpts.LabelSelector = opts.roleBindingSelector()
+		}),
+	}
+	if len(opts.Namespaces) == 1 {
+		factoryOpts = append(factoryOpts, informers.WithNamespace(opts.Namespaces[0]))
+	}
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, 0, factoryOpts...)
+	informer := factory.Rbac().V1().RoleBindings().Informer()
+
+	if err := informer.SetWatchErrorHandler(func(_ *cache.Reflector, err error) {
+		if ctx.Err() == nil {
+			opts.logWarn(fmt.Sprintf("Watch of the Tenant RoleBindings failed, relisting: %v", err), "error", err)
+		}
+	}); err != nil {
+		return fmt.Errorf("failed to watch Tenant RoleBindings: %w", err)
+	}
+
+	//The RoleBindings of the initial list created before the run started were migrated by it, the creation
+	//timestamps only have a second precision so the ones of that second are migrated again, harmlessly
+	since := opts.startTime.Truncate(time.Second)
+	added := make(chan *rbacv1.RoleBinding)
+	_, err = informer.AddEventHandler(cache.ResourceEventHandlerDetailedFuncs{
+		AddFunc: func(obj interface{}, isInInitialList bool) {
+			rb, ok := obj.(*rbacv1.RoleBinding)
+			if !ok || (isInInitialList && rb.CreationTimestamp.Time.Before(since)) {
+				return
+			}
+			//The informer cache is shared, the migration mutates its own copy
+			select {
+			case added <- rb.DeepCopy():
+			case <-ctx.Done():
+			}
+		},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to watch Tenant RoleBindings: %w", err)
+	}
+
+	factory.Start(ctx.Done())
+	defer factory.Shutdown()
+
+	var refresh <-chan time.Time
+	if opts.WatchRefresh > 0 {
+		ticker := time.NewTicker(opts.WatchRefresh)
+		defer ticker.Stop()
+		refresh = ticker.C
+	}
+
+	opts.logInfo(fmt.Sprintf("Watching Tenant RoleBindings matching %s, interrupt to stop", opts.roleBindingSelector()), "selector", opts.roleBindingSelector())
+	watched := 0
+	for {
+		select {
+		case <-ctx.Done():
+			opts.logInfo(fmt.Sprintf("Stopped watching Tenant RoleBindings after %d new ones: %v", watched, context.Cause(ctx)), "count", watched)
+			return opts.writeWatchMetrics(result)
+		case rb := <-added:
+			watched++
+			if err := migrateWatchedRoleBinding(clientset, dynclient, idMap, rb, opts, result, ctx); err != nil {
+				if ctx.Err() != nil {
+					continue
+				}
+				opts.logError(err.Error(), "namespace", rb.Namespace, "name", rb.Name, "error", err)
+			}
+		case <-refresh:
+			//Users created in the directory since the last load must not stay cached as not found
+			opts.forgetUsers()
+			//A failed refresh keeps the previous ids rather than stopping the watch
+			refreshed, err := loadIDMap(config, opts, &Result{}, ctx)
+			if err != nil {
+				if ctx.Err() == nil {
+					opts.logWarn(fmt.Sprintf("Failed to refresh the account ids, keeping the previous ones: %v", err), "error", err)
+				}
+				continue
+			}
+			idMap = refreshed
+			opts.logInfo(fmt.Sprintf("Refreshed the ids of %d accounts", len(idMap)), "count", len(idMap))
+		}
+	}
+}
+
+// migrateWatchedRoleBinding migrates a Tenant RoleBinding reported by the watch with the same selection and
+// mutation rules as the run, applies the result and adds it to result
+func migrateWatchedRoleBinding(clientset kubernetes.Interface, dynclient dynamic.Interface, idMap map[string]string, rb *rbacv1.RoleBinding, opts *MigrateOptions, result *Result, ctx context.Context) error {
+	rbList := opts.selectRoleBindings([]rbacv1.RoleBinding{*rb})
+	if len(rbList) == 0 {
+		return nil
+	}
+	opts.logInfo(fmt.Sprintf("New Tenant RoleBinding %s in Namespace %s", rb.Name, rb.Namespace), "namespace", rb.Namespace, "name", rb.Name)
+
+	rbList, err := opts.splitSubjects(rbList)
+	if err != nil {
+		return err
+	}
+
+	if opts.ExpandGroups {
+		members, err := getGroupMembers(dynclient, rbList, opts, ctx)
+		if err != nil {
+			return err
+		}
+		rbList = expandGroupSubjects(rbList, members)
+	}
+
+	ssoIDs := make(map[string]bool, len(idMap))
+	for _, id := range idMap {
+		ssoIDs[id] = true
+	}
+	nsSummary := &NamespaceSummary{Namespace: rb.Namespace}
+	mrbList, mappings, _, err := mutateNamespaceRoleBindings(idMap, ssoIDs, rbList, opts, nsSummary, func() {})
+	if err != nil {
+		return err
+	}
+	result.Stats.RoleBindingsMutated += len(mrbList)
+	result.Stats.RoleBindingsSkipped += nsSummary.SkippedTotal()
+	result.Stats.AlreadyMigrated += nsSummary.AlreadyMigrated
+	if len(mrbList) == 0 {
+		return nil
+	}
+
+	var stats MigrationStats
+	_, applyErr := applyRoleBindings(clientset, mrbList, opts, &stats, ctx)
+	if applyErr != nil && !errors.Is(applyErr, ErrApplyFailed) {
+		result.Stats.RoleBindingsFailed++
+		return applyErr
+	}
+	result.Stats.RoleBindingsCreated += stats.RoleBindingsCreated
+	result.Stats.RoleBindingsUpdated += stats.RoleBindingsUpdated
+	result.Stats.RoleBindingsUnchanged += stats.RoleBindingsUnchanged
+	result.Stats.RoleBindingsFailed += stats.RoleBindingsFailed
+	result.RoleBindings = append(result.RoleBindings, mrbList...)
+	result.Mappings = append(result.Mappings, mappings...)
+
+	return applyErr
+}
+
+// writeWatchMetrics rewrites the --metrics-file with the counters including the watched RoleBindings
+func (o *MigrateOptions) writeWatchMetrics(result *Result) error {
+	if o.MetricsFile == "" {
+		return nil
+	}
+	if err := result.Stats.WriteMetrics(o.MetricsFile); err != nil {
+		return fmt.Errorf("failed to write metrics file: %w", err)
+	}
+	o.logInfo(fmt.Sprintf("Wrote metrics to %s", o.MetricsFile), "file", o.MetricsFile)
+
+	return nil
+}
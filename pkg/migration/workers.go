@@ -0,0 +1,48 @@
+/*
+Copyright © 2025 Red Hat, Inc.
+*/
+
+package migration
+
+import "sync"
+
+// forEachNamespace calls fn with the index and name of each of namespaces from up to workers goroutines,
+// returning the first error. Namespaces not started yet when fn fails are not processed
+func forEachNamespace(namespaces []string, workers int, fn func(i int, namespace string) error) error {
+	workers = min(workers, len(namespaces))
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		next     int
+		firstErr error
+	)
+	for range workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				mu.Lock()
+				if firstErr != nil || next == len(namespaces) {
+					mu.Unlock()
+					return
+				}
+				i := next
+				next++
+				mu.Unlock()
+
+				if err := fn(i, namespaces[i]); err != nil {
+					mu.Lock()
+					if firstErr == nil {
+						firstErr = err
+					}
+					mu.Unlock()
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	return firstErr
+}